@@ -50,6 +50,8 @@ func NewFile(cfg FileConfig) *File {
 	return f
 }
 
+// NewMakefile creates a new code generation file for a Makefile, or another file with # comments such as a justfile
+// or a Taskfile.yml.
 func NewMakefile(cfg FileConfig) *File {
 	f := &File{
 		cfg: cfg,
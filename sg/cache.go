@@ -0,0 +1,390 @@
+package sg
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const cacheDir = "cache"
+
+// CacheConfig configures a cached target, see Cached.
+type CacheConfig struct {
+	// Inputs are glob patterns, relative to the git root, of the files read by the target.
+	// Patterns use the syntax of path.Match, with the addition of "**" to match any number of directories.
+	Inputs []string
+	// Outputs are the files and directories, relative to the git root, written by the target.
+	// The outputs are removed before they are restored from the cache.
+	Outputs []string
+	// Versions are additional values that invalidate the cache when changed,
+	// such as the versions of tools that are not provided by sage.
+	Versions []string
+}
+
+// Cached returns a Target that only runs target when its inputs have changed.
+//
+// Before running target, a cache key is computed by hashing the target ID, the content of the input files that are not
+// ignored by git, the
+// output paths, the sources of the sagefile, the Go version, the module versions of the sagefile (which includes the
+// versions of the sage tools) and cfg.Versions. If the key is found in the cache, the outputs are restored from the cache and target is skipped.
+// Otherwise target is run and its outputs are stored in the cache.
+//
+// In dry-run mode, see WithDryRun, target is run without restoring outputs from or storing outputs in the cache.
+//
+// The cache is stored in .sage/build/cache by default, see WithCacheStore for other cache stores.
+// Failures to read from or write to the cache are logged, and do not fail the target.
+func Cached(target any, cfg CacheConfig) Target {
+	return cachedTarget{target: checkFunctions(target)[0], cfg: cfg}
+}
+
+type cachedTarget struct {
+	target Target
+	cfg    CacheConfig
+}
+
+// Name implements Target.
+func (c cachedTarget) Name() string {
+	return c.target.Name()
+}
+
+// ID implements Target.
+func (c cachedTarget) ID() string {
+	return c.target.ID()
+}
+
+// Run implements Target.
+func (c cachedTarget) Run(ctx context.Context) error {
+	if err := validateOutputs(c.cfg.Outputs); err != nil {
+		return err
+	}
+	if IsDryRun(ctx) {
+		// Commands are not run in dry-run mode, so the outputs would not be the outputs of the target.
+		Logger(ctx).Println("dry run, not restoring outputs from or storing outputs in cache")
+		return c.target.Run(ctx)
+	}
+	root := FromGitRoot()
+	store := getCacheStore(ctx)
+	key, err := cacheKey(ctx, root, c.target.ID(), c.cfg)
+	if err != nil {
+		return fmt.Errorf("compute cache key: %w", err)
+	}
+	switch err := restoreCacheEntry(ctx, store, key, root, c.cfg.Outputs); {
+	case err == nil:
+		Logger(ctx).Printf("inputs unchanged, restored outputs from cache (%s)", key[:12])
+		markCached(ctx)
+		return nil
+	case !errors.Is(err, ErrCacheMiss):
+		Logger(ctx).Printf("failed to restore outputs from cache: %v", err)
+	}
+	if err := c.target.Run(ctx); err != nil {
+		return err
+	}
+	for _, output := range c.cfg.Outputs {
+		if _, err := os.Lstat(filepath.Join(root, output)); err != nil {
+			Logger(ctx).Printf("not storing outputs in cache, output %s was not written: %v", output, err)
+			return nil
+		}
+	}
+	var archive bytes.Buffer
+	if err := archiveOutputs(&archive, root, c.cfg.Outputs); err != nil {
+		Logger(ctx).Printf("failed to store outputs in cache: %v", err)
+		return nil
+	}
+	keys := []string{key}
+	// Targets that modify their own inputs, such as formatters, are also cached under the key of the
+	// modified inputs, so that the next run is a cache hit.
+	postKey, err := cacheKey(ctx, root, c.target.ID(), c.cfg)
+	if err != nil {
+		return fmt.Errorf("compute cache key: %w", err)
+	}
+	if postKey != key {
+		keys = append(keys, postKey)
+	}
+	for _, key := range keys {
+		if err := store.Put(ctx, key, bytes.NewReader(archive.Bytes())); err != nil {
+			Logger(ctx).Printf("failed to store outputs in cache: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateOutputs returns an error if any of the outputs is not a path below the git root.
+func validateOutputs(outputs []string) error {
+	for _, output := range outputs {
+		if clean := filepath.Clean(output); !filepath.IsLocal(clean) || clean == "." {
+			return fmt.Errorf("invalid cache output %q: must be a path below the git root", output)
+		}
+	}
+	return nil
+}
+
+// cacheKey computes the cache key of a target with the provided ID and cache config.
+func cacheKey(ctx context.Context, root, id string, cfg CacheConfig) (string, error) {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "target %s\n", id)
+	sources, err := sagefileSources()
+	if err != nil {
+		return "", err
+	}
+	_, _ = fmt.Fprintf(h, "sagefile %s\n", sources)
+	for _, version := range cacheVersions() {
+		_, _ = fmt.Fprintf(h, "version %s\n", version)
+	}
+	for _, version := range cfg.Versions {
+		_, _ = fmt.Fprintf(h, "version %s\n", version)
+	}
+	inputs, err := globFiles(ctx, root, cfg.Inputs)
+	if err != nil {
+		return "", err
+	}
+	for _, input := range inputs {
+		sum, err := hashFile(filepath.Join(root, filepath.FromSlash(input)))
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "input %s %s\n", input, sum)
+	}
+	for _, output := range cfg.Outputs {
+		_, _ = fmt.Fprintf(h, "output %s\n", filepath.ToSlash(filepath.Clean(output)))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sagefileSources returns the hash of the sources of the running sagefile, see hashSagefileSources.
+//
+//nolint:gochecknoglobals
+var sagefileSources = sync.OnceValues(func() (string, error) {
+	return hashSagefileSources(FromSageDir())
+})
+
+// hashSagefileSources returns a hash of the sources that the sagefile in sageDir is built from: the Go files, go.mod
+// and go.sum of sageDir, and the Go files and go.mod files of the local directories in the inputs file of the sagefile,
+// see writeSageFileInputs. Files are named by their path relative to sageDir, so that the hash does not depend on
+// where the repository is checked out.
+func hashSagefileSources(sageDir string) (string, error) {
+	dirs := []string{sageDir}
+	switch content, err := os.ReadFile(filepath.Join(sageDir, binDir, sageFileInputs)); {
+	case err == nil:
+		for _, dir := range strings.Split(string(content), "\n") {
+			if dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+	var files []string
+	for _, dir := range dirs {
+		goFiles, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			return "", err
+		}
+		files = append(files, goFiles...)
+		files = append(files, filepath.Join(dir, "go.mod"), filepath.Join(dir, "go.sum"))
+	}
+	slices.Sort(files)
+	h := sha256.New()
+	for _, file := range slices.Compact(files) {
+		sum, err := hashFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(sageDir, file)
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "source %s %s\n", filepath.ToSlash(rel), sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cacheVersions returns the Go version and the module versions of the running binary.
+func cacheVersions() []string {
+	result := []string{runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return result
+	}
+	for _, module := range append([]*debug.Module{&info.Main}, info.Deps...) {
+		for m := module; m != nil; m = m.Replace {
+			result = append(result, m.Path+"@"+m.Version+" "+m.Sum)
+		}
+	}
+	return result
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// archiveOutputs writes the output files and directories below root to w as a gzipped tar archive.
+func archiveOutputs(w io.Writer, root string, outputs []string) error {
+	var files []string
+	for _, output := range outputs {
+		if err := filepath.WalkDir(filepath.Join(root, output), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("output %s: %w", output, err)
+		}
+	}
+	sort.Strings(files)
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		if err := addToArchive(tw, root, file); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addToArchive(tw *tar.Writer, root, file string) error {
+	f, err := os.Open(filepath.Join(root, file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     filepath.ToSlash(file),
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// restoreCacheEntry replaces the outputs below root with the outputs stored under key in store.
+// Returns an error wrapping ErrCacheMiss if the key is not in the store.
+func restoreCacheEntry(ctx context.Context, store CacheStore, key, root string, outputs []string) error {
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// Remove the outputs first, so that files that are not part of the cached outputs are not left behind.
+	for _, output := range outputs {
+		if err := os.RemoveAll(filepath.Join(root, output)); err != nil {
+			return err
+		}
+	}
+	return extractOutputs(r, root, outputs)
+}
+
+// extractOutputs extracts a gzipped tar archive created by archiveOutputs to root.
+// Entries that are not inside any of the outputs are rejected, so that a corrupt or tampered cache entry can not
+// overwrite other files in the repository.
+func extractOutputs(r io.Reader, root string, outputs []string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if !filepath.IsLocal(name) || header.Typeflag != tar.TypeReg || !isInOutputs(name, outputs) {
+			return fmt.Errorf("invalid cache entry %s", header.Name)
+		}
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := writeFileFrom(path, tr, fs.FileMode(header.Mode).Perm()); err != nil {
+			return err
+		}
+	}
+}
+
+// isInOutputs returns true if the local path name is one of the outputs, or inside one of them.
+func isInOutputs(name string, outputs []string) bool {
+	for _, output := range outputs {
+		output = filepath.Clean(output)
+		if name == output || strings.HasPrefix(name, output+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFileFrom writes the content of r to the file path. The content is written to a temporary file that replaces
+// path when the content has been read, so that a failed read, such as a truncated download, does not leave a
+// partially written file behind.
+func writeFileFrom(path string, r io.Reader, perm fs.FileMode) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+	//nolint:gosec // cache entries are written by sage
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,274 @@
+package sg
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCacheKey(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	root := t.TempDir()
+	if err := exec.Command("git", "init", "-q", root).Run(); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(root, "proto", "api.proto"), "syntax = \"proto3\";")
+	writeTestFile(t, filepath.Join(root, "README.md"), "# readme")
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "node_modules/\n")
+	cfg := CacheConfig{Inputs: []string{"proto/**/*.proto"}, Outputs: []string{"gen"}}
+	key, err := cacheKey(ctx, root, "main.Generate()", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Changing files that are not inputs does not change the key.
+	writeTestFile(t, filepath.Join(root, "README.md"), "# changed")
+	if got, err := cacheKey(ctx, root, "main.Generate()", cfg); err != nil || got != key {
+		t.Errorf("expected unchanged key %s, got %s (%v)", key, got, err)
+	}
+	// Changing files that are ignored by git does not change the key.
+	writeTestFile(t, filepath.Join(root, "node_modules", "dep", "dep.proto"), "syntax = \"proto3\";")
+	if got, err := cacheKey(ctx, root, "main.Generate()", cfg); err != nil || got != key {
+		t.Errorf("expected unchanged key %s, got %s (%v)", key, got, err)
+	}
+	// Changing inputs changes the key.
+	writeTestFile(t, filepath.Join(root, "proto", "api.proto"), "syntax = \"proto2\";")
+	if got, err := cacheKey(ctx, root, "main.Generate()", cfg); err != nil || got == key {
+		t.Errorf("expected changed key, got %s (%v)", got, err)
+	}
+	// Changing versions changes the key.
+	cfg.Versions = []string{"buf v1.0.0"}
+	if got, err := cacheKey(ctx, root, "main.Generate()", cfg); err != nil || got == key {
+		t.Errorf("expected changed key, got %s (%v)", got, err)
+	}
+}
+
+func TestHashSagefileSources(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	sageDir := filepath.Join(root, ".sage")
+	writeTestFile(t, filepath.Join(sageDir, "go.mod"), "module example.com/sage\n")
+	writeTestFile(t, filepath.Join(sageDir, "main.go"), "package main\n")
+	writeTestFile(t, filepath.Join(sageDir, "tools", "tool.go"), "package tools\n")
+	writeTestFile(t, filepath.Join(root, "lib", "lib.go"), "package lib\n")
+	writeTestFile(t, filepath.Join(sageDir, binDir, sageFileInputs), filepath.Join(root, "lib")+"\n")
+	previous, err := hashSagefileSources(sageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		path    string
+		changed bool
+	}{
+		{path: filepath.Join(sageDir, "main.go"), changed: true},
+		{path: filepath.Join(sageDir, "go.sum"), changed: true},
+		{path: filepath.Join(root, "lib", "lib.go"), changed: true},
+		// Packages that are not in the inputs file are not sources of the sagefile.
+		{path: filepath.Join(sageDir, "tools", "tool.go"), changed: false},
+	} {
+		writeTestFile(t, tt.path, "package changed\n")
+		current, err := hashSagefileSources(sageDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed := current != previous; changed != tt.changed {
+			t.Errorf("%s: expected changed hash to be %v", tt.path, tt.changed)
+		}
+		previous = current
+	}
+}
+
+func TestCached_DryRun(t *testing.T) {
+	if storeDir := os.Getenv("SAGE_TEST_CACHE_STORE"); storeDir != "" {
+		ctx := WithCacheStore(WithDryRun(context.Background(), true), NewDirCacheStore(storeDir))
+		target := Cached(func(ctx context.Context) error {
+			return Command(ctx, "sh", "-c", "echo generated > gen/api.pb.go").Run()
+		}, CacheConfig{Inputs: []string{"proto/*.proto"}, Outputs: []string{"gen"}})
+		if err := target.Run(ctx); err != nil {
+			NewLogger("test").Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	t.Parallel()
+	dir, storeDir := t.TempDir(), t.TempDir()
+	if err := exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, "proto", "api.proto"), "syntax = \"proto3\";")
+	writeTestFile(t, filepath.Join(dir, "gen", "api.pb.go"), "package gen")
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(executable, "-test.run=^TestCached_DryRun$")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "SAGE_TEST_CACHE_STORE="+storeDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	entries, err := os.ReadDir(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("expected no outputs to be stored in dry-run mode, got %v", entries)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "gen", "api.pb.go")); err != nil || string(content) != "package gen" {
+		t.Errorf("expected the outputs to be unchanged in dry-run mode, got %q (%v)", content, err)
+	}
+}
+
+func TestArchiveOutputs(t *testing.T) {
+	t.Parallel()
+	src, dst := t.TempDir(), t.TempDir()
+	writeTestFile(t, filepath.Join(src, "gen", "a", "api.pb.go"), "package a")
+	writeTestFile(t, filepath.Join(src, "gen", "b.go"), "package gen")
+	var archive bytes.Buffer
+	if err := archiveOutputs(&archive, src, []string{"gen"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := extractOutputs(&archive, dst, []string{"gen"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"gen/a/api.pb.go", "gen/b.go"} {
+		expected, err := os.ReadFile(filepath.Join(src, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := os.ReadFile(filepath.Join(dst, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("%s: expected %q, got %q", file, expected, actual)
+		}
+	}
+}
+
+func TestExtractOutputs_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		name    string
+		entries []string
+	}{
+		{name: "outside outputs", entries: []string{"gen/api.pb.go", "Makefile"}},
+		{name: "output prefix", entries: []string{"generated/api.pb.go"}},
+		{name: "parent directory", entries: []string{"gen/../Makefile"}},
+		{name: "absolute", entries: []string{"/tmp/gen/api.pb.go"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var archive bytes.Buffer
+			gz := gzip.NewWriter(&archive)
+			tw := tar.NewWriter(gz)
+			for _, entry := range tt.entries {
+				if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: entry, Mode: 0o644, Size: 1}); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := tw.Write([]byte("x")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := gz.Close(); err != nil {
+				t.Fatal(err)
+			}
+			dst := t.TempDir()
+			if err := extractOutputs(&archive, dst, []string{"gen"}); err == nil {
+				t.Error("expected error for an entry that is not inside an output")
+			}
+			if _, err := os.Stat(filepath.Join(dst, "Makefile")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected no file outside of the outputs to be written, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractOutputs_Truncated(t *testing.T) {
+	t.Parallel()
+	src, dst := t.TempDir(), t.TempDir()
+	writeTestFile(t, filepath.Join(src, "gen", "api.pb.go"), strings.Repeat("package gen\n", 1000))
+	var archive bytes.Buffer
+	if err := archiveOutputs(&archive, src, []string{"gen"}); err != nil {
+		t.Fatal(err)
+	}
+	truncated := bytes.NewReader(archive.Bytes()[:archive.Len()/2])
+	if err := extractOutputs(truncated, dst, []string{"gen"}); err == nil {
+		t.Fatal("expected error for a truncated archive")
+	}
+	entries, err := os.ReadDir(filepath.Join(dst, "gen"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("expected no partially written outputs, got %v", entries)
+	}
+}
+
+func TestRestoreCacheEntry(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	src, dst := t.TempDir(), t.TempDir()
+	store := NewDirCacheStore(t.TempDir())
+	writeTestFile(t, filepath.Join(src, "gen", "api.pb.go"), "package gen")
+	var archive bytes.Buffer
+	if err := archiveOutputs(&archive, src, []string{"gen"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "key", &archive); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dst, "gen", "stale.pb.go"), "package gen")
+	writeTestFile(t, filepath.Join(dst, "README.md"), "# readme")
+	if err := restoreCacheEntry(ctx, store, "missing", dst, []string{"gen"}); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("expected cache miss, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "gen", "stale.pb.go")); err != nil {
+		t.Errorf("expected outputs to be kept on a cache miss: %v", err)
+	}
+	if err := restoreCacheEntry(ctx, store, "key", dst, []string{"gen"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "gen", "api.pb.go")); err != nil {
+		t.Errorf("expected restored output: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "gen", "stale.pb.go")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected stale output to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "README.md")); err != nil {
+		t.Errorf("expected files that are not outputs to be kept: %v", err)
+	}
+}
+
+func TestValidateOutputs(t *testing.T) {
+	t.Parallel()
+	if err := validateOutputs([]string{"gen", "api/gen/", "./bin/tool"}); err != nil {
+		t.Errorf("expected valid outputs, got %v", err)
+	}
+	for _, output := range []string{".", "", "../gen", "/tmp/gen", "gen/../.."} {
+		if err := validateOutputs([]string{output}); err == nil {
+			t.Errorf("expected error for output %q", output)
+		}
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
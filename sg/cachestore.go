@@ -0,0 +1,197 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrCacheMiss is returned by a CacheStore when a key is not in the cache.
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheStore stores the outputs of cached targets, see Cached.
+type CacheStore interface {
+	// Get returns the entry stored under key, or an error wrapping ErrCacheMiss if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put stores an entry under key.
+	Put(ctx context.Context, key string, r io.Reader) error
+}
+
+type cacheStoreContextKey struct{}
+
+// WithCacheStore returns a context where cached targets use store.
+//
+// Without a store in the context, the cache store is configured by environment variables:
+// SAGE_CACHE_DIR sets a local directory to use instead of .sage/build/cache, for example to share the cache between
+// checkouts, and SAGE_CACHE_URL sets the base URL of an HTTP cache to use in addition to the local directory.
+func WithCacheStore(ctx context.Context, store CacheStore) context.Context {
+	return context.WithValue(ctx, cacheStoreContextKey{}, store)
+}
+
+func getCacheStore(ctx context.Context) CacheStore {
+	if store, ok := ctx.Value(cacheStoreContextKey{}).(CacheStore); ok {
+		return store
+	}
+	dir, ok := os.LookupEnv("SAGE_CACHE_DIR")
+	if !ok || dir == "" {
+		dir = FromBuildDir(cacheDir)
+	}
+	store := NewDirCacheStore(dir)
+	if baseURL, ok := os.LookupEnv("SAGE_CACHE_URL"); ok && baseURL != "" {
+		return tieredCacheStore{local: store, remote: NewHTTPCacheStore(baseURL)}
+	}
+	return store
+}
+
+// NewDirCacheStore returns a CacheStore that stores entries as files in dir.
+func NewDirCacheStore(dir string) CacheStore {
+	return dirCacheStore{dir: dir}
+}
+
+type dirCacheStore struct {
+	dir string
+}
+
+// Get implements CacheStore.
+func (s dirCacheStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", key, ErrCacheMiss)
+	}
+	return f, err
+}
+
+// Put implements CacheStore.
+func (s dirCacheStore) Put(_ context.Context, key string, r io.Reader) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that concurrent readers never see partial entries.
+	tmp, err := os.CreateTemp(s.dir, "."+key+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, key))
+}
+
+// NewHTTPCacheStore returns a CacheStore backed by an HTTP remote cache.
+//
+// Entries are read with GET and written with PUT requests to <baseURL>/<key>, where a missing entry is signaled by
+// status 404. This is the protocol of Gradle's HTTP build cache. Credentials can be provided as user info in baseURL.
+// Requests time out after 2 minutes.
+func NewHTTPCacheStore(baseURL string) CacheStore {
+	return httpCacheStore{baseURL: strings.TrimSuffix(baseURL, "/"), client: &http.Client{Timeout: httpCacheTimeout}}
+}
+
+// httpCacheTimeout is the timeout of requests to HTTP cache stores, including reading the response body.
+const httpCacheTimeout = 2 * time.Minute
+
+type httpCacheStore struct {
+	baseURL string
+	client  *http.Client
+}
+
+// Get implements CacheStore.
+func (s httpCacheStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/"+url.PathEscape(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", key, ErrCacheMiss)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		resp.Body.Close()
+		return nil, fmt.Errorf("get %s: status code %d", key, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// Put implements CacheStore.
+func (s httpCacheStore) Put(ctx context.Context, key string, r io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.baseURL+"/"+url.PathEscape(key), r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("put %s: status code %d", key, resp.StatusCode)
+	}
+	return nil
+}
+
+// tieredCacheStore reads from a local store before a remote store, and writes to both.
+// Entries found in the remote store are copied to the local store, if possible.
+type tieredCacheStore struct {
+	local, remote CacheStore
+}
+
+// Get implements CacheStore.
+func (s tieredCacheStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := s.local.Get(ctx, key)
+	if !errors.Is(err, ErrCacheMiss) {
+		return r, err
+	}
+	r, err = s.remote.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	entry, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.local.Put(ctx, key, bytes.NewReader(entry)); err != nil {
+		Logger(ctx).Printf("failed to copy remote cache entry to local cache: %v", err)
+	}
+	return io.NopCloser(bytes.NewReader(entry)), nil
+}
+
+// Put implements CacheStore.
+func (s tieredCacheStore) Put(ctx context.Context, key string, r io.Reader) error {
+	local, err := os.CreateTemp("", "sage-cache-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(local.Name())
+	defer local.Close()
+	if _, err := io.Copy(local, r); err != nil {
+		return err
+	}
+	for _, store := range []CacheStore{s.local, s.remote} {
+		if _, err := local.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := store.Put(ctx, key, local); err != nil {
+			return err
+		}
+	}
+	return nil
+}
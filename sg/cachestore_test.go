@@ -0,0 +1,130 @@
+package sg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCacheStores(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	entries := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			entry, ok := entries[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(entry)
+		case http.MethodPut:
+			entry, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			entries[r.URL.Path] = entry
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+	for _, tt := range []struct {
+		name  string
+		store CacheStore
+	}{
+		{name: "dir", store: NewDirCacheStore(t.TempDir())},
+		{name: "http", store: NewHTTPCacheStore(server.URL + "/cache/")},
+		{
+			name: "tiered",
+			store: tieredCacheStore{
+				local:  NewDirCacheStore(t.TempDir()),
+				remote: NewHTTPCacheStore(server.URL + "/tiered"),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			if _, err := tt.store.Get(ctx, "key"); !errors.Is(err, ErrCacheMiss) {
+				t.Fatalf("expected cache miss, got %v", err)
+			}
+			if err := tt.store.Put(ctx, "key", strings.NewReader("value")); err != nil {
+				t.Fatal(err)
+			}
+			r, err := tt.store.Get(ctx, "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			value, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(value) != "value" {
+				t.Errorf("expected %q, got %q", "value", value)
+			}
+		})
+	}
+
+	t.Run("tiered copies remote entries to local", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		remote := NewHTTPCacheStore(server.URL + "/shared")
+		if err := remote.Put(ctx, "key", strings.NewReader("remote")); err != nil {
+			t.Fatal(err)
+		}
+		local := NewDirCacheStore(t.TempDir())
+		r, err := tieredCacheStore{local: local, remote: remote}.Get(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = r.Close()
+		r, err = local.Get(ctx, "key")
+		if err != nil {
+			t.Fatalf("expected entry in local store, got %v", err)
+		}
+		_ = r.Close()
+	})
+	t.Run("tiered returns remote entries when the local store fails", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		remote := NewHTTPCacheStore(server.URL + "/failing")
+		if err := remote.Put(ctx, "key", strings.NewReader("remote")); err != nil {
+			t.Fatal(err)
+		}
+		r, err := tieredCacheStore{local: failingCacheStore{}, remote: remote}.Get(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		value, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(value) != "remote" {
+			t.Errorf("expected %q, got %q", "remote", value)
+		}
+	})
+}
+
+// failingCacheStore is a CacheStore without entries that fails to store entries.
+type failingCacheStore struct{}
+
+func (failingCacheStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("%s: %w", key, ErrCacheMiss)
+}
+
+func (failingCacheStore) Put(context.Context, string, io.Reader) error {
+	return errors.New("disk full")
+}
@@ -0,0 +1,292 @@
+package sg
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/doc"
+	"go/types"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"go.einride.tech/sage/internal/strcase"
+)
+
+// usageExitCode is the exit code of the sagefile binary when it is invoked with an unknown target or invalid
+// arguments, to tell usage errors apart from targets that fail with exit code 1. It is EX_USAGE from sysexits.h, since
+// exit code 2 is used by the Go runtime for panics.
+const usageExitCode = 64
+
+// cliTarget describes a target in the sagefile command line interface.
+type cliTarget struct {
+	Name        string   `json:"name"`
+	Namespace   string   `json:"namespace,omitempty"`
+	MakeTarget  string   `json:"makeTarget"`
+	Group       string   `json:"group,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Doc         string   `json:"doc,omitempty"`
+	Hidden      bool     `json:"hidden,omitempty"`
+	Args        []cliArg `json:"args"`
+}
+
+// cliArg describes an argument of a target in the sagefile command line interface.
+type cliArg struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Default *string  `json:"default,omitempty"`
+	Values  []string `json:"values,omitempty"`
+}
+
+// cliTargets returns the targets of the sagefile command line interface.
+func cliTargets(pkg *sagePackage, mks []Makefile) []cliTarget {
+	result := []cliTarget{}
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if ok, _ := shouldBeGenerated(mks, function.Recv); !ok {
+			return
+		}
+		target := cliTarget{
+			Name:        getTargetFunctionName(function),
+			Namespace:   function.Recv,
+			MakeTarget:  effectiveMakeTarget(function),
+			Group:       getTargetGroup(function),
+			Description: pkg.Synopsis(function.Doc),
+			Doc:         strings.TrimSpace(function.Doc),
+			Hidden:      isHiddenTarget(function),
+			Args:        []cliArg{},
+		}
+		defaults := getTargetDefaults(function)
+		for _, param := range function.Decl.Type.Params.List[1:] {
+			for _, name := range param.Names {
+				arg := cliArg{
+					Name: strcase.ToSnake(name.Name),
+					Type: types.ExprString(param.Type),
+				}
+				if value, ok := defaults[arg.Name]; ok {
+					arg.Default = &value
+				}
+				switch customParamKind(pkg, param.Type) {
+				case boolParam:
+					arg.Values = []string{"true", "false"}
+				case namedStringParam:
+					arg.Values = typeConstValues(findType(pkg, arg.Type))
+				}
+				target.Args = append(target.Args, arg)
+			}
+		}
+		result = append(result, target)
+	})
+	return result
+}
+
+// ParseCommandLine handles the commands of the sagefile command line interface that are not target invocations,
+// and translates "run" commands to a target and its positional arguments.
+//
+// The commands are:
+//
+//	sagefile run <target> [--arg name=value ...]
+//	sagefile run <target> --help
+//	sagefile list [--json]
+//	sagefile completion bash|zsh|fish
+//	sagefile --help
+//
+// Usage errors exit the process with exit code 64, and targets that fail exit with exit code 1.
+//
+// ParseCommandLine is called by the generated sagefile entrypoint with the targets of the sagefile encoded as JSON,
+// and should not be called from sagefiles.
+func ParseCommandLine(targetsJSON, command string, args []string) (target string, targetArgs []string) {
+	var targets []cliTarget
+	if err := json.Unmarshal([]byte(targetsJSON), &targets); err != nil {
+		panic(err)
+	}
+	logger := NewLogger("sagefile")
+	switch command {
+	case "-h", "--help":
+		writeCLIUsage(os.Stdout)
+		os.Exit(0)
+	case "list":
+		if err := listTargets(os.Stdout, targets, args); err != nil {
+			logger.Println(err)
+			os.Exit(usageExitCode)
+		}
+		os.Exit(0)
+	case completeCommand:
+		for _, candidate := range completeCommandLine(targets, args) {
+			_, _ = fmt.Println(candidate)
+		}
+		os.Exit(0)
+	case completionCommand:
+		if err := writeCompletionScript(os.Stdout, strings.Join(args, " ")); err != nil {
+			logger.Println(err)
+			os.Exit(usageExitCode)
+		}
+		os.Exit(0)
+	case "run":
+		target, targetArgs, err := parseRunArgs(targets, args)
+		if err != nil {
+			logger.Println(err)
+			os.Exit(usageExitCode)
+		}
+		if target == nil {
+			os.Exit(0)
+		}
+		return target.Name, targetArgs
+	}
+	return command, args
+}
+
+func writeCLIUsage(w io.Writer) {
+	_, _ = fmt.Fprint(w, `Usage:
+  sagefile run <target> [--arg name=value ...]  Run a target.
+  sagefile run <target> --help                  Show the arguments of a target.
+  sagefile list [--json]                        List the targets.
+  sagefile help [namespace]                     Show the help of the generated Makefiles.
+  sagefile completion bash|zsh|fish             Print a shell completion script.
+  sagefile watch <target> [args ...]            Run a target each time files change.
+
+Exit codes: 0 on success, 1 when the target fails and 64 on usage errors.
+`)
+}
+
+func listTargets(w io.Writer, targets []cliTarget, args []string) error {
+	var asJSON bool
+	for _, arg := range args {
+		switch arg {
+		case "--json":
+			asJSON = true
+		default:
+			return fmt.Errorf("list: unknown flag %s", arg)
+		}
+	}
+	visible := []cliTarget{}
+	for _, target := range targets {
+		if !target.Hidden {
+			visible = append(visible, target)
+		}
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(visible)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, target := range visible {
+		argNames := make([]string, 0, len(target.Args))
+		for _, arg := range target.Args {
+			if arg.Default != nil {
+				argNames = append(argNames, "["+arg.Name+"]")
+			} else {
+				argNames = append(argNames, arg.Name)
+			}
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", target.Name, strings.Join(argNames, " "), target.Description)
+	}
+	return tw.Flush()
+}
+
+// parseRunArgs parses the arguments of the run command into a target and its positional arguments.
+// Returns a nil target if only the help of the target was requested.
+func parseRunArgs(targets []cliTarget, args []string) (*cliTarget, []string, error) {
+	if len(args) == 0 {
+		return nil, nil, fmt.Errorf("run: missing target, see sagefile list")
+	}
+	target := findCLITarget(targets, args[0])
+	if target == nil {
+		return nil, nil, fmt.Errorf("run: unknown target %s, see sagefile list", args[0])
+	}
+	values := make(map[string]string, len(target.Args))
+	for i := 1; i < len(args); i++ {
+		var nameValue string
+		switch arg := args[i]; {
+		case arg == "-h" || arg == "--help":
+			writeTargetUsage(os.Stdout, target)
+			return nil, nil, nil
+		case arg == "--arg":
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf("run %s: missing value for --arg", target.Name)
+			}
+			i++
+			nameValue = args[i]
+		case strings.HasPrefix(arg, "--arg="):
+			nameValue = strings.TrimPrefix(arg, "--arg=")
+		default:
+			return nil, nil, fmt.Errorf(
+				"run %s: unexpected argument %s, arguments are passed as --arg name=value", target.Name, arg,
+			)
+		}
+		name, value, ok := strings.Cut(nameValue, "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("run %s: argument %q must have the form name=value", target.Name, nameValue)
+		}
+		name = strcase.ToSnake(name)
+		if !targetHasArg(target, name) {
+			return nil, nil, fmt.Errorf("run %s: unknown argument %s", target.Name, name)
+		}
+		values[name] = value
+	}
+	result := make([]string, 0, len(target.Args))
+	var missing []string
+	for _, arg := range target.Args {
+		value, ok := values[arg.Name]
+		switch {
+		case ok:
+			result = append(result, value)
+		case arg.Default != nil:
+			result = append(result, *arg.Default)
+		default:
+			missing = append(missing, arg.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("run %s: missing arguments: %s", target.Name, strings.Join(missing, ", "))
+	}
+	return target, result, nil
+}
+
+// findCLITarget returns the target with name, or nil if there is no such target.
+func findCLITarget(targets []cliTarget, name string) *cliTarget {
+	for i := range targets {
+		if targets[i].Name == name {
+			return &targets[i]
+		}
+	}
+	return nil
+}
+
+func targetHasArg(target *cliTarget, name string) bool {
+	for _, arg := range target.Args {
+		if arg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writeTargetUsage(w io.Writer, target *cliTarget) {
+	_, _ = fmt.Fprintf(w, "Usage: sagefile run %s", target.Name)
+	if len(target.Args) > 0 {
+		_, _ = fmt.Fprint(w, " [--arg name=value ...]")
+	}
+	_, _ = fmt.Fprintln(w)
+	if target.Doc != "" {
+		_, _ = fmt.Fprintf(w, "\n%s\n", target.Doc)
+	}
+	if len(target.Args) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w, "\nArguments:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, arg := range target.Args {
+		var details []string
+		if arg.Default != nil {
+			details = append(details, fmt.Sprintf("default %q", *arg.Default))
+		} else {
+			details = append(details, "required")
+		}
+		if len(arg.Values) > 0 {
+			details = append(details, "one of "+strings.Join(arg.Values, ", "))
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\n", arg.Name, arg.Type, strings.Join(details, ", "))
+	}
+	_ = tw.Flush()
+}
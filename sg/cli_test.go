@@ -0,0 +1,91 @@
+package sg
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestParseRunArgs(t *testing.T) {
+	t.Parallel()
+	dev := "dev"
+	targets := []cliTarget{
+		{Name: "Build"},
+		{Name: "Deploy", Args: []cliArg{{Name: "service", Type: "string"}, {Name: "env", Type: "string", Default: &dev}}},
+	}
+	for _, tt := range []struct {
+		name         string
+		args         []string
+		expected     []string
+		errorMessage string
+	}{
+		{name: "no args", args: []string{"Build"}, expected: []string{}},
+		{name: "default", args: []string{"Deploy", "--arg", "service=api"}, expected: []string{"api", "dev"}},
+		{
+			name:     "any order",
+			args:     []string{"Deploy", "--arg=env=prod", "--arg", "service=api"},
+			expected: []string{"api", "prod"},
+		},
+		{name: "missing target", args: []string{}, errorMessage: "run: missing target, see sagefile list"},
+		{name: "unknown target", args: []string{"Test"}, errorMessage: "run: unknown target Test, see sagefile list"},
+		{name: "missing argument", args: []string{"Deploy"}, errorMessage: "run Deploy: missing arguments: service"},
+		{
+			name:         "unknown argument",
+			args:         []string{"Deploy", "--arg", "region=eu"},
+			errorMessage: "run Deploy: unknown argument region",
+		},
+		{
+			name:         "positional argument",
+			args:         []string{"Deploy", "api"},
+			errorMessage: "run Deploy: unexpected argument api, arguments are passed as --arg name=value",
+		},
+		{
+			name:         "missing value",
+			args:         []string{"Deploy", "--arg"},
+			errorMessage: "run Deploy: missing value for --arg",
+		},
+		{
+			name:         "malformed argument",
+			args:         []string{"Deploy", "--arg", "service"},
+			errorMessage: `run Deploy: argument "service" must have the form name=value`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, got, err := parseRunArgs(targets, tt.args)
+			if tt.errorMessage != "" {
+				if err == nil || err.Error() != tt.errorMessage {
+					t.Fatalf("expected error %q but got %v", tt.errorMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("expected args %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestListTargets(t *testing.T) {
+	t.Parallel()
+	dev := "dev"
+	targets := []cliTarget{
+		{Name: "Build", Description: "Build builds."},
+		{Name: "Deploy", Args: []cliArg{{Name: "service"}, {Name: "env", Default: &dev}}},
+		{Name: "Helper", Hidden: true},
+	}
+	var b bytes.Buffer
+	if err := listTargets(&b, targets, nil); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "Build                  Build builds.\nDeploy  service [env]  \n"
+	if b.String() != expected {
+		t.Errorf("expected %q but got %q", expected, b.String())
+	}
+	if err := listTargets(&b, targets, []string{"--yaml"}); err == nil {
+		t.Error("expected error for unknown flag")
+	}
+}
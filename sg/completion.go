@@ -0,0 +1,202 @@
+package sg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go.einride.tech/sage/internal/strcase"
+)
+
+const (
+	// completionCommand is the sagefile command that prints the completion script for a shell.
+	completionCommand = "completion"
+	// completeCommand is the hidden sagefile command that the completion scripts call to get completion candidates.
+	// Its arguments are the words before the word being completed, and it prints one candidate per line as
+	// "value<TAB>description". A line with an empty value is a hint that is shown by shells that support it.
+	completeCommand = "__complete"
+)
+
+// completionShells are the shells with completion scripts, in the order they are listed.
+//
+//nolint:gochecknoglobals
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionScripts are the completion scripts for the sagefile binary, keyed by shell.
+//
+//nolint:gochecknoglobals
+var completionScripts = map[string]string{
+	"bash": `_sagefile() {
+	local line="${COMP_LINE:0:COMP_POINT}" words cur
+	read -ra words <<<"$line"
+	if [[ -z $line || $line == *[[:space:]] ]]; then
+		words+=("")
+	fi
+	cur="${words[${#words[@]}-1]}"
+	local IFS=$'\n'
+	COMPREPLY=($(compgen -W "$("${words[0]}" __complete "${words[@]:1:${#words[@]}-2}" 2>/dev/null | cut -f1)" -- "$cur"))
+	# Bash completes words after the last word break, such as ":" in targets and "=" in --arg values, so remove the
+	# part of the candidates before it.
+	if [[ $cur == *[$COMP_WORDBREAKS]* ]]; then
+		local prefix="${cur%"${cur##*[$COMP_WORDBREAKS]}"}"
+		COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+	fi
+}
+complete -F _sagefile sagefile
+`,
+	"zsh": `#compdef sagefile
+_sagefile() {
+	local -a lines candidates
+	local line
+	lines=("${(@f)$("${words[1]}" __complete "${(@)words[2,CURRENT-1]}" 2>/dev/null)}")
+	for line in "${lines[@]}"; do
+		if [[ $line == $'\t'* ]]; then
+			_message -r "${line#$'\t'}"
+		elif [[ -n $line ]]; then
+			candidates+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+		fi
+	done
+	(( ${#candidates} )) && _describe -t values value candidates
+}
+compdef _sagefile sagefile
+`,
+	"fish": `function __sagefile_complete
+	set -l tokens (commandline -opc)
+	$tokens[1] __complete $tokens[2..-1] 2>/dev/null | string match -rv '^\t'
+end
+complete -c sagefile -f -a '(__sagefile_complete)'
+`,
+}
+
+// completeCommandLine returns the completion candidates of the word after args, as "value<TAB>description".
+func completeCommandLine(targets []cliTarget, args []string) []string {
+	if len(args) == 0 {
+		return append(commandCandidates(), targetCandidates(targets)...)
+	}
+	switch args[0] {
+	case "run":
+		return completeRunArgs(targets, args[1:])
+	case "watch":
+		return completeTargetArgs(targets, args[1:])
+	case "list":
+		if len(args) == 1 {
+			return []string{"--json\tList the targets as JSON"}
+		}
+		return nil
+	case completionCommand:
+		if len(args) > 1 {
+			return nil
+		}
+		result := make([]string, 0, len(completionShells))
+		for _, shell := range completionShells {
+			result = append(result, shell+"\tshell")
+		}
+		return result
+	default:
+		return completeTargetArgs(targets, args)
+	}
+}
+
+// commandCandidates returns the completion candidates of the sagefile commands.
+func commandCandidates() []string {
+	return []string{
+		"run\tRun a target",
+		"list\tList the targets",
+		"help\tShow the help of the generated Makefiles",
+		"completion\tPrint a shell completion script",
+		"watch\tRun a target each time files change",
+	}
+}
+
+// targetCandidates returns the completion candidates of the targets that are not hidden.
+func targetCandidates(targets []cliTarget) []string {
+	result := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if target.Hidden {
+			continue
+		}
+		description := target.Description
+		if target.Group != "" {
+			description = "[" + target.Group + "] " + description
+		}
+		result = append(result, target.Name+"\t"+description)
+	}
+	return result
+}
+
+// completeTargetArgs returns the completion candidates of a target invocation with positional arguments.
+func completeTargetArgs(targets []cliTarget, args []string) []string {
+	if len(args) == 0 {
+		return targetCandidates(targets)
+	}
+	target := findCLITarget(targets, args[0])
+	if target == nil || len(args) > len(target.Args) {
+		return nil
+	}
+	return argCandidates(target.Args[len(args)-1], "")
+}
+
+// completeRunArgs returns the completion candidates of the arguments of the run command.
+func completeRunArgs(targets []cliTarget, args []string) []string {
+	if len(args) == 0 {
+		return targetCandidates(targets)
+	}
+	target := findCLITarget(targets, args[0])
+	if target == nil {
+		return nil
+	}
+	set := map[string]bool{}
+	for i := 1; i < len(args); i++ {
+		nameValue, ok := strings.CutPrefix(args[i], "--arg=")
+		if args[i] == "--arg" && i+1 < len(args) {
+			i++
+			nameValue, ok = args[i], true
+		}
+		if name, _, hasValue := strings.Cut(nameValue, "="); ok && hasValue {
+			set[strcase.ToSnake(name)] = true
+		}
+	}
+	if args[len(args)-1] == "--arg" {
+		var result []string
+		for _, arg := range target.Args {
+			if !set[arg.Name] {
+				result = append(result, argCandidates(arg, arg.Name+"=")...)
+			}
+		}
+		return result
+	}
+	result := []string{"--help\tShow the arguments of the target"}
+	if len(set) < len(target.Args) {
+		result = append(result, "--arg\tSet an argument as name=value")
+	}
+	return result
+}
+
+// argCandidates returns the completion candidates of the value of arg, with prefix prepended to the values.
+// Arguments with a known set of values complete to the values. Other arguments complete to prefix if it is not
+// empty, and otherwise to a hint with their name and type.
+func argCandidates(arg cliArg, prefix string) []string {
+	if len(arg.Values) == 0 {
+		if prefix != "" {
+			return []string{fmt.Sprintf("%s\t%s (%s)", prefix, arg.Name, arg.Type)}
+		}
+		return []string{fmt.Sprintf("\t%s (%s)", arg.Name, arg.Type)}
+	}
+	result := make([]string, 0, len(arg.Values))
+	for _, value := range arg.Values {
+		result = append(result, prefix+value+"\t"+arg.Name)
+	}
+	return result
+}
+
+// writeCompletionScript writes the completion script for shell to w.
+func writeCompletionScript(w io.Writer, shell string) error {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return fmt.Errorf(
+			"completion: unsupported shell %q, expected one of: %s", shell, strings.Join(completionShells, ", "),
+		)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
@@ -0,0 +1,233 @@
+package sg
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+const completionTestSagefile = `package main
+
+import (
+	"context"
+
+	"go.einride.tech/sage/sg"
+)
+
+func main() {
+	sg.GenerateMakefiles(sg.Makefile{Path: sg.FromGitRoot("Makefile")})
+}
+
+type Env string
+
+const (
+	EnvDev  Env = "dev"
+	EnvProd Env = "prod"
+)
+
+// Build builds the project.
+//
+//sage:group build
+func Build(ctx context.Context) error { return nil }
+
+// Deploy deploys a service.
+func Deploy(ctx context.Context, env Env, service string, force bool) error { return nil }
+
+//sage:hidden
+func Helper(ctx context.Context) error { return nil }
+`
+
+func TestCompleteCommandLine(t *testing.T) {
+	t.Parallel()
+	targets := []cliTarget{
+		{Name: "Build", Group: "build", Description: "Build builds the project.", Args: []cliArg{}},
+		{
+			Name:        "Deploy",
+			Description: "Deploy deploys a service.",
+			Args: []cliArg{
+				{Name: "env", Type: "Env", Values: []string{"dev", "prod"}},
+				{Name: "service", Type: "string"},
+			},
+		},
+		{Name: "Helper", Hidden: true, Args: []cliArg{}},
+	}
+	for _, tt := range []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name: "commands and targets",
+			expected: append(
+				commandCandidates(),
+				"Build\t[build] Build builds the project.",
+				"Deploy\tDeploy deploys a service.",
+			),
+		},
+		{
+			name:     "positional value",
+			args:     []string{"Deploy"},
+			expected: []string{"dev\tenv", "prod\tenv"},
+		},
+		{
+			name:     "positional hint",
+			args:     []string{"Deploy", "dev"},
+			expected: []string{"\tservice (string)"},
+		},
+		{
+			name: "positional too many",
+			args: []string{"Deploy", "dev", "api"},
+		},
+		{
+			name:     "run target",
+			args:     []string{"run"},
+			expected: []string{"Build\t[build] Build builds the project.", "Deploy\tDeploy deploys a service."},
+		},
+		{
+			name:     "run flags",
+			args:     []string{"run", "Deploy"},
+			expected: []string{"--help\tShow the arguments of the target", "--arg\tSet an argument as name=value"},
+		},
+		{
+			name:     "run flags without args",
+			args:     []string{"run", "Build"},
+			expected: []string{"--help\tShow the arguments of the target"},
+		},
+		{
+			name:     "run arg",
+			args:     []string{"run", "Deploy", "--arg"},
+			expected: []string{"env=dev\tenv", "env=prod\tenv", "service=\tservice (string)"},
+		},
+		{
+			name:     "run arg already set",
+			args:     []string{"run", "Deploy", "--arg=env=dev", "--arg"},
+			expected: []string{"service=\tservice (string)"},
+		},
+		{
+			name:     "run all args set",
+			args:     []string{"run", "Deploy", "--arg", "env=dev", "--arg", "service=api"},
+			expected: []string{"--help\tShow the arguments of the target"},
+		},
+		{
+			name: "run unknown target",
+			args: []string{"run", "Unknown"},
+		},
+		{
+			name:     "watch",
+			args:     []string{"watch", "Deploy"},
+			expected: []string{"dev\tenv", "prod\tenv"},
+		},
+		{
+			name:     "list",
+			args:     []string{"list"},
+			expected: []string{"--json\tList the targets as JSON"},
+		},
+		{
+			name:     "completion",
+			args:     []string{"completion"},
+			expected: []string{"bash\tshell", "zsh\tshell", "fish\tshell"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := completeCommandLine(targets, tt.args); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGenerateInitFile_Complete(t *testing.T) {
+	t.Parallel()
+	moduleRoot, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "go.mod"), "module example.com/sagefile\n\ngo 1.25\n\n"+
+		"require go.einride.tech/sage v0.0.0\n\nreplace go.einride.tech/sage => "+moduleRoot+"\n")
+	writeTestFile(t, filepath.Join(dir, "main.go"), completionTestSagefile)
+	pkg, err := loadSagePackage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initFile := codegen.NewFile(codegen.FileConfig{
+		Filename:    "generating_sagefile.go",
+		Package:     pkg.Name,
+		GeneratedBy: "go.einride.tech/sage",
+	})
+	if err := generateInitFile(initFile, pkg, []Makefile{{Path: filepath.Join(dir, "Makefile")}}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := initFile.GoContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, "generating_sagefile.go"), string(content))
+	build := exec.Command("go", "build", "-o", "sagefile", ".")
+	build.Dir = dir
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build sagefile: %v\n%s", err, output)
+	}
+	for _, tt := range []struct {
+		args     []string
+		expected []string
+	}{
+		{
+			args:     []string{"run"},
+			expected: []string{"Build\t[build] Build builds the project.", "Deploy\tDeploy deploys a service."},
+		},
+		{
+			args: []string{"run", "Deploy", "--arg"},
+			expected: []string{
+				"env=dev\tenv",
+				"env=prod\tenv",
+				"service=\tservice (string)",
+				"force=true\tforce",
+				"force=false\tforce",
+			},
+		},
+		{
+			args:     []string{"Deploy", "prod", "api"},
+			expected: []string{"true\tforce", "false\tforce"},
+		},
+	} {
+		cmd := exec.Command(filepath.Join(dir, "sagefile"), append([]string{completeCommand}, tt.args...)...)
+		cmd.Dir = dir
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s %v: %v", completeCommand, tt.args, err)
+		}
+		if got := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"); !slices.Equal(got, tt.expected) {
+			t.Errorf("%s %v: expected %q but got %q", completeCommand, tt.args, tt.expected, got)
+		}
+	}
+	var exitErr *exec.ExitError
+	cmd := exec.Command(filepath.Join(dir, "sagefile"), completionCommand, "tcsh")
+	if err := cmd.Run(); !errors.As(err, &exitErr) || exitErr.ExitCode() != usageExitCode {
+		t.Errorf("expected %s tcsh to exit with code %d, got %v", completionCommand, usageExitCode, err)
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	t.Parallel()
+	for _, shell := range completionShells {
+		var script strings.Builder
+		if err := writeCompletionScript(&script, shell); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(script.String(), completeCommand) {
+			t.Errorf("completion script for %s does not call %s", shell, completeCommand)
+		}
+	}
+	if err := writeCompletionScript(&strings.Builder{}, "tcsh"); err == nil {
+		t.Error("expected error for an unsupported shell")
+	}
+}
@@ -17,9 +17,21 @@ import (
 // Dependencies must be of type func(context.Context) error or Target.
 //
 // Each function will be run exactly once, even across multiple calls to Deps.
+//
+// When fail-fast mode is enabled, see WithFailFast, the first failing dependency cancels the context passed to
+// its siblings, and siblings that fail after the cancellation are reported as cancelled instead of failed.
+//
+// The number of dependencies running at the same time can be limited, see WithMaxParallelism.
+//
+// If any dependency fails, Deps fails the target that called it, which fails the targets that depend on it, up to the
+// top-level target of the run.
 func Deps(ctx context.Context, functions ...any) {
 	errs := make([]error, len(functions))
 	checkedFunctions := checkFunctions(functions...)
+	reacquireSlot := yieldSlot(ctx)
+	defer reacquireSlot()
+	failFast := newFailFastGroup(ctx)
+	defer failFast.cancel()
 	var wg sync.WaitGroup
 	for i, f := range checkedFunctions {
 		dependencies := getDependencies(ctx)
@@ -33,36 +45,92 @@ func Deps(ctx context.Context, functions ...any) {
 				panic(msg)
 			}
 		}
-		ctx := withDependency(ctx, f)
+		if failFast.skip() {
+			errs[i] = failFast.ctx.Err()
+			failFast.done(i, errs[i], errs[i])
+			continue
+		}
+		ctx := withDependency(failFast.ctx, f)
 
+		run := func() {
+			defer func() {
+				ctxErr := ctx.Err()
+				if v := recover(); v != nil {
+					errs[i] = panicError(v)
+				}
+				failFast.done(i, errs[i], ctxErr)
+			}()
+			errs[i] = runDependency(ctx, f)
+		}
 		// Forcing serial deps can protect low-powered build machines from running out of memory.
 		// EXPERIMENTAL: Support for this environment variable may be removed at any time.
+		// Prefer SAGE_MAX_PARALLELISM=1, which also applies to nested calls to Deps.
 		if forceSerialDeps, ok := os.LookupEnv("SAGE_FORCE_SERIAL_DEPS"); ok && isTrue(forceSerialDeps) {
-			errs[i] = runner.RunOnce(WithLogger(ctx, NewLogger(f.Name())), f.ID(), f.Run)
+			run()
 			continue
 		}
 		wg.Add(1)
 		go func() {
-			defer func() {
-				if v := recover(); v != nil {
-					errs[i] = fmt.Errorf("%s", v)
-				}
-				wg.Done()
-			}()
-			errs[i] = runner.RunOnce(WithLogger(ctx, NewLogger(f.Name())), f.ID(), f.Run)
+			defer wg.Done()
+			run()
 		}()
 	}
 	wg.Wait()
-	var exitError bool
+	var failed, cancelled []string
 	for i, err := range errs {
-		if err != nil {
-			NewLogger(checkedFunctions[i].Name()).Println(err)
-			exitError = true
+		if err == nil {
+			continue
+		}
+		if failFast.isCancelled(i) {
+			markSkipped(ctx, checkedFunctions[i])
+			NewLogger(checkedFunctions[i].Name()).Printf("cancelled: %v", err)
+			cancelled = append(cancelled, loggerPrefix(checkedFunctions[i].Name()))
+			continue
 		}
+		NewLogger(checkedFunctions[i].Name()).Println(err)
+		failed = append(failed, loggerPrefix(checkedFunctions[i].Name()))
 	}
-	if exitError {
-		os.Exit(1)
+	if len(failed) == 0 && len(cancelled) == 0 {
+		return
 	}
+	if _, ok := ctx.Value(targetRunContextKey{}).(*targetRun); !ok {
+		// Not called from a target run, so there is no RunMain to return the error to.
+		exit(1)
+	}
+	// Fail the calling target, and the targets depending on it, so that their output is flushed before RunMain
+	// returns the error.
+	panic(&dependencyError{failed: failed, cancelled: cancelled})
+}
+
+// dependencyError is the error of a target that failed because some of its dependencies failed.
+// It is raised as a panic by Deps, which does not return errors, and recovered by RunMain and the Deps of dependent
+// targets.
+//
+// Dependencies that were cancelled in fail-fast mode because a sibling failed first are listed separately, so that
+// the error points at the dependencies that caused the failure.
+type dependencyError struct {
+	failed    []string
+	cancelled []string
+}
+
+// Error implements error.
+func (e *dependencyError) Error() string {
+	var parts []string
+	if len(e.failed) > 0 {
+		parts = append(parts, "failed dependencies: "+strings.Join(e.failed, ", "))
+	}
+	if len(e.cancelled) > 0 {
+		parts = append(parts, "cancelled dependencies: "+strings.Join(e.cancelled, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// panicError returns the error of a target that panicked with v.
+func panicError(v any) error {
+	if err, ok := v.(*dependencyError); ok {
+		return err
+	}
+	return fmt.Errorf("%s", v)
 }
 
 // SerialDeps works like Deps except running all dependencies serially instead of in parallel.
@@ -72,6 +140,31 @@ func SerialDeps(ctx context.Context, targets ...any) {
 	}
 }
 
+// runDependency runs f exactly once, holding a slot from the parallelism limit while it runs.
+func runDependency(ctx context.Context, f Target) error {
+	var chain []string
+	if parent, ok := ctx.Value(targetRunContextKey{}).(*targetRun); ok {
+		chain = parent.chain()
+	}
+	return runner.RunOnce(ctx, f.ID(), func(ctx context.Context) (err error) {
+		ctx, output := withTargetOutput(ctx)
+		defer func() {
+			if v := recover(); v != nil {
+				flushTargetOutput(f.Name(), output, fmt.Errorf("%s", v))
+				panic(v)
+			}
+			flushTargetOutput(f.Name(), output, err)
+		}()
+		ctx = withTargetLoggers(ctx, f.Name(), chain)
+		ctx, release, err := withSlot(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return runRecorded(ctx, f)
+	})
+}
+
 func checkFunctions(functions ...any) []Target {
 	result := make([]Target, 0, len(functions))
 	for _, f := range functions {
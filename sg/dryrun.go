@@ -0,0 +1,99 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type dryRunContextKey struct{}
+
+// WithDryRun returns a context where commands created with Command print what they would run instead of running.
+//
+// Dry-run mode can also be enabled for a whole run by setting the environment variable SAGE_DRY_RUN=true.
+func WithDryRun(ctx context.Context, dryRun bool) context.Context {
+	return context.WithValue(ctx, dryRunContextKey{}, dryRun)
+}
+
+// IsDryRun reports whether ctx is in dry-run mode, see WithDryRun.
+// Tools can use it to report what they would do instead of doing it.
+func IsDryRun(ctx context.Context) bool {
+	if dryRun, ok := ctx.Value(dryRunContextKey{}).(bool); ok {
+		return dryRun
+	}
+	value, ok := os.LookupEnv("SAGE_DRY_RUN")
+	return ok && isTrue(value)
+}
+
+// dryRunArgs are the args of the command that a dry-run command starts instead of the original command.
+// The shell does nothing and exits successfully, and ignores the args of the original command, which follow.
+//
+//nolint:gochecknoglobals
+var dryRunArgs = []string{"sh", "-c", "exit 0"}
+
+// dryRunCommand is a command in dry-run mode, see toDryRunCommand.
+type dryRunCommand struct {
+	cmd     *exec.Cmd
+	out     io.Writer
+	printed sync.Once
+}
+
+// toDryRunCommand changes cmd to start a shell that does nothing instead of the command, and returns a dryRunCommand
+// that prints the command line, working directory and extra environment of cmd to out when cmd is started.
+//
+// The args of cmd are kept after the args of the shell, so that any changes made to cmd after it was created, such as
+// setting its working directory or appending args, are printed.
+func toDryRunCommand(cmd *exec.Cmd, out io.Writer) *dryRunCommand {
+	cmd.Args = append(slices.Clone(dryRunArgs), cmd.Args...)
+	cmd.Path = "/bin/sh"
+	if path, err := exec.LookPath(dryRunArgs[0]); err == nil {
+		cmd.Path = path
+	}
+	cmd.Err = nil // the command does not have to exist
+	return &dryRunCommand{cmd: cmd, out: out}
+}
+
+// args returns the args of the original command.
+func (d *dryRunCommand) args() []string {
+	return d.cmd.Args[len(dryRunArgs):]
+}
+
+// print prints the command line, working directory and extra environment of the command, once.
+func (d *dryRunCommand) print() {
+	d.printed.Do(func() {
+		var b strings.Builder
+		_, _ = fmt.Fprintln(&b, "dry run:", shellJoin(d.args()))
+		dir := d.cmd.Dir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		_, _ = fmt.Fprintln(&b, "  dir:", dir)
+		// Only print the environment that differs from the default environment set up by Command.
+		defaultEnviron := prependPath(os.Environ(), FromBinDir())
+		for _, kv := range d.cmd.Env {
+			if !slices.Contains(defaultEnviron, kv) {
+				_, _ = fmt.Fprintln(&b, "  env:", kv)
+			}
+		}
+		_, _ = io.WriteString(d.out, b.String())
+	})
+}
+
+// shellJoin joins args to a command line, quoting args that would not be read as single words by a shell.
+func shellJoin(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "" || strings.ContainsFunc(arg, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=,+@%", r))
+		}) {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
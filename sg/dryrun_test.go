@@ -0,0 +1,62 @@
+package sg
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func Test_shellJoin(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{"go", "test", "./..."}, expected: "go test ./..."},
+		{args: []string{"git", "commit", "-m", "fix: a bug"}, expected: "git commit -m 'fix: a bug'"},
+		{args: []string{"echo", "it's"}, expected: `echo 'it'\''s'`},
+		{args: []string{"echo", ""}, expected: "echo ''"},
+		{args: []string{"terraform", "apply", "-var=env=prod"}, expected: "terraform apply -var=env=prod"},
+	} {
+		t.Run(tt.expected, func(t *testing.T) {
+			t.Parallel()
+			if got := shellJoin(tt.args); got != tt.expected {
+				t.Errorf("shellJoin(%q) = %s, want %s", tt.args, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestToDryRunCommand(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	cmd := exec.Command("terraform-does-not-exist", "apply")
+	cmd.Env = prependPath(os.Environ(), FromBinDir())
+	dryRun := toDryRunCommand(cmd, &out)
+	// Changes made after the command was created are printed.
+	dir := t.TempDir()
+	cmd.Dir = dir
+	cmd.Args = append(cmd.Args, "-var=env=prod")
+	cmd.Env = append(cmd.Env, "TF_WORKSPACE=prod")
+	cmd.Stderr = &logWriter{logger: log.New(&out, "", 0), out: &out, dryRun: dryRun}
+	// The command does nothing and succeeds, and has no output.
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output) != 0 {
+		t.Errorf("expected no output, got %q", output)
+	}
+	expected := strings.Join([]string{
+		"dry run: terraform-does-not-exist apply -var=env=prod",
+		"  dir: " + dir,
+		"  env: TF_WORKSPACE=prod",
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("expected dry-run output:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
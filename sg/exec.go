@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -23,6 +25,10 @@ func ContextWithEnv(ctx context.Context, env ...string) context.Context {
 }
 
 // Command should be used when returning exec.Cmd from tools to set opinionated standard fields.
+//
+// In dry-run mode, see WithDryRun, the returned command prints its command line, working directory and extra
+// environment when run, instead of running. The command line is printed if stdout or stderr of the command is not
+// replaced, and a command in dry-run mode has no output.
 func Command(ctx context.Context, path string, args ...string) *exec.Cmd {
 	cmd := exec.CommandContext(ctx, path)
 	cmd.Args = append(cmd.Args, args...)
@@ -35,26 +41,61 @@ func Command(ctx context.Context, path string, args ...string) *exec.Cmd {
 		}
 	}
 	cmd.Env = prependPath(cmd.Env, FromBinDir())
-	cmd.Stderr = newLogWriter(ctx, os.Stderr)
-	cmd.Stdout = newLogWriter(ctx, os.Stdout)
+	record := recordCommand(ctx, cmd)
+	stderr := newLogWriter(ctx, outputWriter(ctx, os.Stderr), path, record)
+	stdout := newLogWriter(ctx, outputWriter(ctx, os.Stdout), path, record)
+	if IsDryRun(ctx) {
+		// Print to stderr so that callers reading the output of the command do not get the dry-run output.
+		dryRun := toDryRunCommand(cmd, stderr)
+		stderr.dryRun, stdout.dryRun = dryRun, dryRun
+	}
+	cmd.Stderr, cmd.Stdout = stderr, stdout
 	return cmd
 }
 
-func newLogWriter(ctx context.Context, out io.Writer) *logWriter {
+func newLogWriter(ctx context.Context, out io.Writer, path string, record *commandRecord) *logWriter {
 	logger := log.New(out, Logger(ctx).Prefix(), 0)
-	return &logWriter{logger: logger, out: out}
+	result := &logWriter{logger: logger, out: out, record: record}
+	if isJSONLogFormat() {
+		result.slogLogger = SlogLogger(ctx).With(commandLogKey, filepath.Base(path))
+	}
+	return result
 }
 
 type logWriter struct {
 	logger            *log.Logger
+	slogLogger        *slog.Logger
 	out               io.Writer
+	record            *commandRecord
+	dryRun            *dryRunCommand
 	hasFileReferences bool
 }
 
+// ReadFrom implements io.ReaderFrom. It is called by exec.Cmd to copy the output of the command from a pipe, from
+// when the command is started until it exits, which is recorded as the run of the command.
+func (l *logWriter) ReadFrom(r io.Reader) (int64, error) {
+	if l.dryRun != nil {
+		l.dryRun.print()
+	}
+	if l.record != nil {
+		args := l.record.cmd.Args
+		if l.dryRun != nil {
+			args = l.dryRun.args()
+		}
+		l.record.started(args)
+		defer l.record.finished()
+	}
+	return io.Copy(struct{ io.Writer }{l}, r)
+}
+
 func (l *logWriter) Write(p []byte) (n int, err error) {
 	in := bufio.NewScanner(bytes.NewReader(p))
 	for in.Scan() {
 		line := in.Text()
+		if l.slogLogger != nil {
+			l.slogLogger.Info(line)
+			continue
+		}
 		if !l.hasFileReferences {
 			l.hasFileReferences = hasFileReferences(line)
 			if l.hasFileReferences {
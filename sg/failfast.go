@@ -0,0 +1,83 @@
+package sg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+type failFastContextKey struct{}
+
+// WithFailFast returns a context where Deps cancels the remaining dependencies as soon as one of them fails.
+//
+// Fail-fast mode can also be enabled for a whole run by setting the environment variable SAGE_FAIL_FAST=true.
+func WithFailFast(ctx context.Context) context.Context {
+	return context.WithValue(ctx, failFastContextKey{}, true)
+}
+
+func isFailFast(ctx context.Context) bool {
+	if failFast, ok := ctx.Value(failFastContextKey{}).(bool); ok {
+		return failFast
+	}
+	value, ok := os.LookupEnv("SAGE_FAIL_FAST")
+	return ok && isTrue(value)
+}
+
+// failFastGroup tracks the dependencies of a single Deps call in fail-fast mode.
+type failFastGroup struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	enabled   bool
+	mu        sync.Mutex
+	failed    bool
+	cancelled map[int]bool
+}
+
+func newFailFastGroup(ctx context.Context) *failFastGroup {
+	if !isFailFast(ctx) {
+		return &failFastGroup{ctx: ctx, cancel: func() {}}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &failFastGroup{ctx: ctx, cancel: cancel, enabled: true, cancelled: map[int]bool{}}
+}
+
+// skip reports whether the remaining dependencies should not be started.
+func (g *failFastGroup) skip() bool {
+	if !g.enabled {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.failed
+}
+
+// done records the result of the i:th dependency, where ctxErr is the error of the dependency's context when it
+// returned. The first error cancels the siblings. An error after that is considered a consequence of the cancellation
+// only if it is a context cancellation error or the dependency's context was already cancelled when it returned, so
+// that siblings that failed on their own are still reported as failed.
+func (g *failFastGroup) done(i int, err, ctxErr error) {
+	if !g.enabled || err == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.failed {
+		if errors.Is(err, context.Canceled) || ctxErr != nil {
+			g.cancelled[i] = true
+		}
+		return
+	}
+	g.failed = true
+	g.cancel()
+}
+
+// isCancelled reports whether the i:th dependency failed because a sibling failed first.
+func (g *failFastGroup) isCancelled(i int) bool {
+	if !g.enabled {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.cancelled[i]
+}
@@ -0,0 +1,71 @@
+package sg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFailFastGroup_Done(t *testing.T) {
+	t.Parallel()
+	g := newFailFastGroup(WithFailFast(context.Background()))
+	g.done(0, errors.New("first"), nil)
+	if g.ctx.Err() == nil {
+		t.Fatal("expected the first error to cancel the siblings")
+	}
+	g.done(1, errors.New("failed on its own"), nil)
+	g.done(2, fmt.Errorf("wrapped: %w", context.Canceled), nil)
+	g.done(3, errors.New("signal: killed"), context.Canceled)
+	g.done(4, nil, context.Canceled)
+	for i, expected := range []bool{false, false, true, true, false} {
+		if got := g.isCancelled(i); got != expected {
+			t.Errorf("isCancelled(%d) = %v, want %v", i, got, expected)
+		}
+	}
+}
+
+func TestDeps_FailFast(t *testing.T) {
+	t.Parallel()
+	ctx, _ := startRecord(WithFailFast(context.Background()), Fn(failFastTestParent))
+	var depErr *dependencyError
+	func() {
+		defer func() {
+			err, ok := recover().(*dependencyError)
+			if !ok {
+				t.Fatalf("expected Deps to panic with a dependency error, got %v", err)
+			}
+			depErr = err
+		}()
+		Deps(ctx, failFastTestFailing, failFastTestBlocking)
+	}()
+	if !slices.Equal(depErr.failed, []string{"sg:fail-fast-test-failing"}) {
+		t.Errorf("expected the failing dependency to be reported as failed, got %v", depErr.failed)
+	}
+	if !slices.Equal(depErr.cancelled, []string{"sg:fail-fast-test-blocking"}) {
+		t.Errorf("expected the blocking dependency to be reported as cancelled, got %v", depErr.cancelled)
+	}
+	const expected = "failed dependencies: sg:fail-fast-test-failing; cancelled dependencies: sg:fail-fast-test-blocking"
+	if depErr.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, depErr.Error())
+	}
+}
+
+func failFastTestParent(context.Context) error {
+	return nil
+}
+
+func failFastTestFailing(context.Context) error {
+	return errors.New("failed")
+}
+
+func failFastTestBlocking(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Minute):
+		return errors.New("not cancelled")
+	}
+}
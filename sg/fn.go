@@ -2,12 +2,14 @@ package sg
 
 import (
 	"context"
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Target represents a target function that can be run with Deps.
@@ -61,10 +63,7 @@ func newFn(f any, args ...any) (Target, error) {
 	}
 	for _, arg := range args {
 		argT := v.Type().In(x)
-		switch argT {
-		case reflect.TypeFor[int](), reflect.TypeFor[string](), reflect.TypeFor[bool]():
-			// ok
-		default:
+		if !isSupportedArgType(argT) {
 			return nil, fmt.Errorf("argument %d (%s), is not a supported argument type", x, argT)
 		}
 		if callArgT := reflect.TypeOf(arg); argT != callArgT {
@@ -115,6 +114,19 @@ func newFn(f any, args ...any) (Target, error) {
 	}, nil
 }
 
+// isSupportedArgType reports whether t is a supported type for target function arguments.
+func isSupportedArgType(t reflect.Type) bool {
+	switch t {
+	case reflect.TypeFor[int](),
+		reflect.TypeFor[bool](),
+		reflect.TypeFor[float64](),
+		reflect.TypeFor[time.Duration](),
+		reflect.TypeFor[[]string]():
+		return true
+	}
+	return t.Kind() == reflect.String || reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]())
+}
+
 type fn struct {
 	name string
 	id   string
@@ -2,7 +2,9 @@ package sg
 
 import (
 	"context"
+	"net/netip"
 	"testing"
+	"time"
 )
 
 func TestFn_Name(t *testing.T) {
@@ -52,3 +54,36 @@ type namespace Namespace
 func (namespace) MyFunc(_ context.Context) error {
 	return nil
 }
+
+func TestFn_ArgTypes(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fn   any
+		arg  any
+		ok   bool
+	}{
+		{name: "float64", fn: func(context.Context, float64) error { return nil }, arg: 1.5, ok: true},
+		{name: "duration", fn: func(context.Context, time.Duration) error { return nil }, arg: time.Second, ok: true},
+		{name: "string slice", fn: func(context.Context, []string) error { return nil }, arg: []string{"a"}, ok: true},
+		{name: "named string", fn: func(context.Context, testEnv) error { return nil }, arg: testEnv("dev"), ok: true},
+		{
+			name: "text unmarshaler",
+			fn:   func(context.Context, netip.Addr) error { return nil },
+			arg:  netip.MustParseAddr("127.0.0.1"),
+			ok:   true,
+		},
+		{name: "int slice", fn: func(context.Context, []int) error { return nil }, arg: []int{1}, ok: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newFn(tt.fn, tt.arg)
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+type testEnv string
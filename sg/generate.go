@@ -1,35 +1,69 @@
 package sg
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"go/doc"
-	"go/parser"
-	"go/token"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"go.einride.tech/sage/internal/codegen"
+	"go.einride.tech/sage/sg/internal/diff"
 )
 
 // GenerateMakefiles defines which Makefiles should be generated.
+//
+// When the environment variable SAGE_CHECK is true, as in "make sage-check", GenerateMakefiles does not build the
+// sagefile or write any files. Instead, it compares the generated files with the files on disk, prints a unified diff
+// of the files that are out of date and exits with exit code 1 if there are any.
 func GenerateMakefiles(mks ...Makefile) {
-	ctx := WithLogger(context.Background(), NewLogger("sage"))
-	Logger(ctx).Println("building binary and generating Makefiles...")
+	// Generating the sagefile is never a dry run, since the sagefile is needed to do a dry run of its targets.
+	ctx := WithDryRun(WithLogger(context.Background(), NewLogger("sage")), false)
+	check := isCheck()
+	if check {
+		Logger(ctx).Println("checking generated Makefiles...")
+	} else {
+		Logger(ctx).Println("building binary and generating Makefiles...")
+	}
 	if len(mks) == 0 {
 		panic("no makefiles to generate, see https://github.com/einride/sage#readme for more info")
 	}
-	// TODO: replace the deprecated function call.
-	pkgs, err := parser.ParseDir(token.NewFileSet(), FromSageDir(), nil, parser.ParseComments) //nolint:staticcheck
+	pkg, err := loadSagePackage(FromSageDir())
 	if err != nil {
-		panic(fmt.Errorf("failed to parse directory: %v", err))
+		panic(err)
+	}
+	if err := loadImportedNamespaces(pkg, FromSageDir(), mks); err != nil {
+		panic(err)
+	}
+	for _, skipped := range skippedTargets(pkg) {
+		Logger(ctx).Printf("warning: skipping target %s", skipped)
 	}
-	if len(pkgs) != 1 {
-		panic(fmt.Errorf("parser returned unexpected number of packages: %d", len(pkgs)))
+	files := generateFiles(ctx, pkg, mks)
+	if check {
+		if !checkGeneratedFiles(ctx, files) {
+			os.Exit(1)
+		}
+		return
 	}
-	var pkg *doc.Package
-	for _, p := range pkgs {
-		pkg = doc.New(p, "./", doc.PreserveAST)
+	buildSagefile(ctx, pkg, mks)
+	for _, file := range files {
+		if err := os.WriteFile(file.path, file.content, 0o600); err != nil {
+			panic(err)
+		}
 	}
+}
+
+func isCheck() bool {
+	value, ok := os.LookupEnv("SAGE_CHECK")
+	return ok && isTrue(value)
+}
+
+// buildSagefile compiles the sagefile binary and writes the files it needs into the sage directory.
+func buildSagefile(ctx context.Context, pkg *sagePackage, mks []Makefile) {
 	// update .gitignore file
 	const gitignoreContent = ".gitignore\ntools/\nbin/\nbuild/\n"
 	if err := os.WriteFile(FromSageDir(".gitignore"), []byte(gitignoreContent), 0o600); err != nil {
@@ -61,19 +95,119 @@ func GenerateMakefiles(mks ...Makefile) {
 	if err := compileCmd.Run(); err != nil {
 		panic(fmt.Errorf("error compiling sagefiles: %w", err))
 	}
-	// Generate makefiles
+	if err := writeSageFileInputs(ctx, FromSageDir(), FromBinDir(sageFileInputs)); err != nil {
+		panic(err)
+	}
+	// Generate dependency graph
+	if err := writeDependencyGraph(newDependencyGraph(pkg, mks)); err != nil {
+		panic(err)
+	}
+}
+
+// writeSageFileInputs writes the local directories that the package in dir is built from to the file path, one per
+// line.
+//
+// The directories are the directories of the packages and the modules of the build that are in the module in dir,
+// such as subdirectories of the sage directory, or in modules that are replaced with local directories, such as
+// go.einride.tech/sage when replaced with a checkout. Other modules are versioned by go.sum.
+func writeSageFileInputs(ctx context.Context, dir, path string) error {
+	const format = `{{with .Module}}{{if or .Main (and .Replace (not .Replace.Version))}}` +
+		`{{$.Dir}}{{"\n"}}{{.Dir}}{{"\n"}}{{end}}{{end}}`
+	var out bytes.Buffer
+	cmd := Command(ctx, "go", "list", "-deps", "-f", format, ".")
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to list the packages of the sagefile: %w", err)
+	}
+	dirs := slices.DeleteFunc(strings.Split(out.String(), "\n"), func(s string) bool { return s == "" })
+	slices.Sort(dirs)
+	dirs = slices.Compact(dirs)
+	var content strings.Builder
+	for _, inputDir := range dirs {
+		_, _ = content.WriteString(inputDir + "\n")
+	}
+	return os.WriteFile(path, []byte(content.String()), 0o600)
+}
+
+// generatedFile is a file generated from the sagefiles.
+type generatedFile struct {
+	path    string
+	content []byte
+}
+
+// generateFiles generates the Makefiles, justfiles and Taskfiles of mks.
+func generateFiles(ctx context.Context, pkg *sagePackage, mks []Makefile) []generatedFile {
+	var result []generatedFile
 	for _, v := range mks {
-		if v.Path == "" {
+		if v.Path == "" && v.Justfile == "" && v.Taskfile == "" {
 			panic("Path needs to be defined")
 		}
-		mk := codegen.NewMakefile(codegen.FileConfig{
-			GeneratedBy: "go.einride.tech/sage",
-		})
-		if err := generateMakefile(ctx, mk, pkg, v, mks...); err != nil {
-			panic(err)
+		if v.Path != "" {
+			mk := codegen.NewMakefile(codegen.FileConfig{
+				GeneratedBy: "go.einride.tech/sage",
+			})
+			if err := generateMakefile(ctx, mk, pkg, v, mks...); err != nil {
+				panic(err)
+			}
+			result = append(result, generatedFile{path: v.Path, content: mk.RawContent()})
+		}
+		if v.Justfile != "" {
+			justfile := codegen.NewMakefile(codegen.FileConfig{
+				GeneratedBy: "go.einride.tech/sage",
+			})
+			if err := generateJustfile(justfile, pkg, v, mks...); err != nil {
+				panic(err)
+			}
+			result = append(result, generatedFile{path: v.Justfile, content: justfile.RawContent()})
 		}
-		if err := os.WriteFile(v.Path, mk.RawContent(), 0o600); err != nil {
+		if v.Taskfile != "" {
+			taskfile := codegen.NewMakefile(codegen.FileConfig{
+				GeneratedBy: "go.einride.tech/sage",
+			})
+			if err := generateTaskfile(taskfile, pkg, v, mks...); err != nil {
+				panic(err)
+			}
+			result = append(result, generatedFile{path: v.Taskfile, content: taskfile.RawContent()})
+		}
+	}
+	return result
+}
+
+// checkGeneratedFiles compares files with the files on disk and prints a unified diff of the files that differ.
+// Returns true if all files are up to date.
+func checkGeneratedFiles(ctx context.Context, files []generatedFile) bool {
+	upToDate := true
+	for _, file := range files {
+		existing, err := os.ReadFile(file.path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
 			panic(err)
 		}
+		name := file.path
+		if rel, err := filepath.Rel(FromGitRoot(), file.path); err == nil {
+			name = rel
+		}
+		fileDiff := diff.Unified(name, name+" (generated)", existing, file.content)
+		if fileDiff == nil {
+			continue
+		}
+		upToDate = false
+		Logger(ctx).Printf("%s is out of date, the sagefile needs regenerating: run make sage", name)
+		_, _ = os.Stdout.Write(fileDiff)
+	}
+	return upToDate
+}
+
+func writeDependencyGraph(graph *dependencyGraph) error {
+	var dot, mermaid bytes.Buffer
+	if err := graph.writeDOT(&dot); err != nil {
+		return err
+	}
+	if err := graph.writeMermaid(&mermaid); err != nil {
+		return err
+	}
+	if err := os.WriteFile(FromBuildDir(graphDOTFile), dot.Bytes(), 0o600); err != nil {
+		return err
 	}
+	return os.WriteFile(FromBuildDir(graphMermaidFile), mermaid.Bytes(), 0o600)
 }
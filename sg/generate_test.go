@@ -0,0 +1,71 @@
+package sg
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSageFileHashCommand_LocalReplace(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "lib", "go.mod"), "module example.com/lib\n\ngo 1.25\n")
+	writeTestFile(t, filepath.Join(root, "lib", "lib.go"), "package lib\n\nfunc Hello() {}\n")
+	sageDir := filepath.Join(root, ".sage")
+	writeTestFile(t, filepath.Join(sageDir, "go.mod"), "module example.com/sage\n\ngo 1.25\n\n"+
+		"require example.com/lib v0.0.0\n\nreplace example.com/lib => ../lib\n")
+	writeTestFile(t, filepath.Join(sageDir, "sub", "sub.go"), "package sub\n\nfunc Hello() {}\n")
+	writeTestFile(t, filepath.Join(sageDir, "main.go"), `package main
+
+import (
+	"example.com/lib"
+	"example.com/sage/sub"
+)
+
+func main() {
+	lib.Hello()
+	sub.Hello()
+}
+`)
+	if err := os.MkdirAll(filepath.Join(sageDir, binDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSageFileInputs(
+		context.Background(), sageDir, filepath.Join(sageDir, binDir, sageFileInputs),
+	); err != nil {
+		t.Fatal(err)
+	}
+	hash := func() string {
+		cmd := exec.Command("sh", "-c", sageFileHashCommand)
+		cmd.Dir = sageDir
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(output)
+	}
+	previous := hash()
+	for _, path := range []string{
+		filepath.Join(root, "lib", "lib.go"),
+		filepath.Join(root, "lib", "go.mod"),
+		filepath.Join(sageDir, "sub", "sub.go"),
+		filepath.Join(sageDir, "main.go"),
+	} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, path, string(content)+"\n// changed\n")
+		if current := hash(); current == previous {
+			t.Errorf("expected the hash to change when %s changes", path)
+		} else {
+			previous = current
+		}
+	}
+	writeTestFile(t, filepath.Join(root, "lib", "new.go"), "package lib\n")
+	if hash() == previous {
+		t.Error("expected the hash to change when a file is added to a local replace target")
+	}
+}
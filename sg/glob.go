@@ -0,0 +1,73 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// globFiles returns the files below root matching any of the glob patterns, as sorted slash-separated paths
+// relative to root.
+//
+// Patterns use the syntax of path.Match, with the addition of "**" to match any number of directories.
+// Only the files of the git repo at root that are not ignored by git are matched, and the .sage directory is never
+// matched.
+func globFiles(ctx context.Context, root string, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to list files of git repo: %w", err)
+	}
+	var result []string
+	for name := range strings.SplitSeq(out.String(), "\x00") {
+		if name == "" || name == sageDir || strings.HasPrefix(name, sageDir+"/") ||
+			!slices.ContainsFunc(patterns, func(pattern string) bool { return matchGlob(pattern, name) }) {
+			continue
+		}
+		// Deleted files are listed until the deletion is staged, and submodules are listed as directories.
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err != nil || info.IsDir() {
+			continue
+		}
+		result = append(result, name)
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+// matchGlob reports whether the slash-separated name matches pattern.
+func matchGlob(pattern, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
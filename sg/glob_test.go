@@ -0,0 +1,30 @@
+package sg
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{pattern: "go.mod", name: "go.mod", expected: true},
+		{pattern: "*.go", name: "main.go", expected: true},
+		{pattern: "*.go", name: "sg/main.go", expected: false},
+		{pattern: "**/*.go", name: "main.go", expected: true},
+		{pattern: "**/*.go", name: "sg/internal/main.go", expected: true},
+		{pattern: "proto/**", name: "proto/einride/v1/api.proto", expected: true},
+		{pattern: "proto/**/*.proto", name: "proto/api.proto", expected: true},
+		{pattern: "proto/**/*.proto", name: "api/api.proto", expected: false},
+		{pattern: "**/testdata/*.json", name: "a/b/testdata/c.json", expected: true},
+		{pattern: "**/testdata/*.json", name: "a/b/testdata/c/d.json", expected: false},
+	} {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := matchGlob(tt.pattern, tt.name); got != tt.expected {
+				t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package sg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GoModulesConfig configures which Go modules are listed by GoModules.
+type GoModulesConfig struct {
+	// Exclude is a list of glob patterns of module directories to exclude, as slash-separated paths relative to the
+	// root of the git repo, such as "examples/**". The module at the root of the git repo has the path ".".
+	// Patterns use the syntax of path.Match, with the addition of "**" to match any number of directories.
+	Exclude []string
+}
+
+// GoModules returns the directories of the Go modules in the current git repo, sorted by path.
+//
+// The modules are the directories with a go.mod file that is not ignored by git. If a go.work file is in use, only
+// the modules it uses are listed. The go.work file is found the same way the go command finds it: GOWORK=off disables
+// it, an absolute path in GOWORK selects it, and otherwise it is searched for from the root of the git repo up. Note
+// that the generated Makefile, justfile and Taskfile default GOWORK to off. Modules with an empty go.mod file, and
+// modules in vendor and testdata directories, are never listed.
+func GoModules(ctx context.Context, config GoModulesConfig) ([]string, error) {
+	return goModules(ctx, FromGitRoot(), os.Getenv("GOWORK"), config)
+}
+
+func goModules(ctx context.Context, root, goWork string, config GoModulesConfig) ([]string, error) {
+	dirs, err := gitGoModules(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	goWorkFile, err := findGoWork(root, goWork)
+	if err != nil {
+		return nil, err
+	}
+	if goWorkFile != "" {
+		used, err := goWorkModules(goWorkFile)
+		if err != nil {
+			return nil, err
+		}
+		dirs = slices.DeleteFunc(dirs, func(dir string) bool { return !slices.Contains(used, dir) })
+	}
+	var result []string
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		if !isGoModule(dir) || isGoToolIgnoredDir(rel) ||
+			slices.ContainsFunc(config.Exclude, func(pattern string) bool { return matchGlob(pattern, rel) }) {
+			continue
+		}
+		if !slices.Contains(result, dir) {
+			result = append(result, dir)
+		}
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+// gitGoModules returns the directories of the go.mod files below root that are not ignored by git.
+func gitGoModules(ctx context.Context, root string) ([]string, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to list files of git repo: %w", err)
+	}
+	var result []string
+	for name := range bytes.SplitSeq(out.Bytes(), []byte{0}) {
+		if path.Base(string(name)) == "go.mod" {
+			result = append(result, filepath.Join(root, filepath.FromSlash(path.Dir(string(name)))))
+		}
+	}
+	return result, nil
+}
+
+// findGoWork returns the path of the go.work file in use for root given the value of GOWORK, or an empty string if no
+// go.work file is in use.
+func findGoWork(root, goWork string) (string, error) {
+	switch goWork {
+	case "off":
+		return "", nil
+	case "", "auto":
+		for dir := root; ; dir = filepath.Dir(dir) {
+			if info, err := os.Stat(filepath.Join(dir, "go.work")); err == nil && !info.IsDir() {
+				return filepath.Join(dir, "go.work"), nil
+			}
+			if filepath.Dir(dir) == dir {
+				return "", nil
+			}
+		}
+	}
+	if !filepath.IsAbs(goWork) {
+		return "", fmt.Errorf("invalid GOWORK: not an absolute path: %s", goWork)
+	}
+	return goWork, nil
+}
+
+// goWorkModules returns the directories of the modules used by the go.work file.
+func goWorkModules(goWorkFile string) ([]string, error) {
+	goWork, err := os.ReadFile(goWorkFile)
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Dir(goWorkFile)
+	var result []string
+	var inBlock bool
+	scanner := bufio.NewScanner(bytes.NewReader(goWork))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			result = append(result, goWorkDir(root, fields[0]))
+		case fields[0] == "use" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "use" && len(fields) == 2:
+			result = append(result, goWorkDir(root, fields[1]))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", goWorkFile, err)
+	}
+	return result, nil
+}
+
+// goWorkDir returns the directory of a use directive in a go.work file in root.
+func goWorkDir(root, dir string) string {
+	if unquoted, err := strconv.Unquote(dir); err == nil {
+		dir = unquoted
+	}
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(root, filepath.FromSlash(dir))
+}
+
+// isGoModule returns true if dir has a go.mod file that is not empty.
+func isGoModule(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil && info.Size() > 0
+}
+
+// isGoToolIgnoredDir returns true if the slash-separated path is in a directory that the go tool ignores.
+func isGoToolIgnoredDir(rel string) bool {
+	return slices.ContainsFunc(strings.Split(rel, "/"), func(elem string) bool {
+		return elem == "vendor" || elem == "testdata"
+	})
+}
@@ -0,0 +1,122 @@
+package sg
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestGoModules(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	if err := exec.Command("git", "init", "-q", root).Run(); err != nil {
+		t.Fatal(err)
+	}
+	const goMod = "module example.com/m\n"
+	writeTestFile(t, filepath.Join(root, "go.mod"), goMod)
+	writeTestFile(t, filepath.Join(root, ".sage", "go.mod"), goMod)
+	writeTestFile(t, filepath.Join(root, "api", "go.mod"), goMod)
+	writeTestFile(t, filepath.Join(root, "examples", "a", "go.mod"), goMod)
+	writeTestFile(t, filepath.Join(root, "empty", "go.mod"), "")
+	writeTestFile(t, filepath.Join(root, "vendor", "example.com", "v", "go.mod"), goMod)
+	writeTestFile(t, filepath.Join(root, "internal", "testdata", "go.mod"), goMod)
+	writeTestFile(t, filepath.Join(root, "ignored", "go.mod"), goMod)
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "ignored/\n")
+	for _, tt := range []struct {
+		name     string
+		goWork   string
+		env      string
+		exclude  []string
+		expected []string
+	}{
+		{
+			name:     "all",
+			env:      "off",
+			expected: []string{".", ".sage", "api", "examples/a"},
+		},
+		{
+			name:     "exclude",
+			env:      "off",
+			exclude:  []string{"examples/**", ".sage"},
+			expected: []string{".", "api"},
+		},
+		{
+			name:     "go.work",
+			goWork:   "go 1.25\n\nuse (\n\t.\n\t./api // the API\n\t\"./ignored\"\n)\n\nuse ./examples/a\n",
+			expected: []string{".", "api", "examples/a"},
+		},
+		{
+			name:     "go.work with GOWORK=auto",
+			goWork:   "go 1.25\n\nuse ./api\n",
+			env:      "auto",
+			expected: []string{"api"},
+		},
+		{
+			name:     "go.work with GOWORK=off",
+			goWork:   "go 1.25\n\nuse ./api\n",
+			env:      "off",
+			expected: []string{".", ".sage", "api", "examples/a"},
+		},
+		{
+			name:     "go.work with exclude",
+			goWork:   "go 1.25\n\nuse (\n\t.\n\t./api\n)\n",
+			exclude:  []string{"."},
+			expected: []string{"api"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := root
+			if tt.goWork != "" {
+				// Each go.work test gets its own root, to not affect the other tests.
+				dir = t.TempDir()
+				if err := exec.Command("cp", "-R", root+"/.", dir).Run(); err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, filepath.Join(dir, "go.work"), tt.goWork)
+			}
+			modules, err := goModules(context.Background(), dir, tt.env, GoModulesConfig{Exclude: tt.exclude})
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := make([]string, 0, len(modules))
+			for _, module := range modules {
+				rel, err := filepath.Rel(dir, module)
+				if err != nil {
+					t.Fatal(err)
+				}
+				actual = append(actual, filepath.ToSlash(rel))
+			}
+			if !slices.Equal(actual, tt.expected) {
+				t.Errorf("expected modules %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGoModules_GoWorkPath(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	if err := exec.Command("git", "init", "-q", root).Run(); err != nil {
+		t.Fatal(err)
+	}
+	const goMod = "module example.com/m\n"
+	writeTestFile(t, filepath.Join(root, "go.mod"), goMod)
+	writeTestFile(t, filepath.Join(root, "api", "go.mod"), goMod)
+	writeTestFile(t, filepath.Join(root, "go.work"), "go 1.25\n\nuse .\n")
+	goWork := filepath.Join(t.TempDir(), "other.work")
+	writeTestFile(t, goWork, "go 1.25\n\nuse "+strconv.Quote(filepath.Join(root, "api"))+"\n")
+	modules, err := goModules(context.Background(), root, goWork, GoModulesConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{filepath.Join(root, "api")}; !slices.Equal(modules, expected) {
+		t.Errorf("expected modules %v, got %v", expected, modules)
+	}
+	if _, err := goModules(context.Background(), root, "other.work", GoModulesConfig{}); err == nil {
+		t.Error("expected error for relative GOWORK")
+	}
+}
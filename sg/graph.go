@@ -0,0 +1,271 @@
+package sg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/types"
+	"io"
+	"slices"
+	"strconv"
+)
+
+const (
+	graphDOTFile     = "graph.dot"
+	graphMermaidFile = "graph.mmd"
+)
+
+// dependencyGraph is the static target dependency graph of a sagefile.
+type dependencyGraph struct {
+	nodes []string
+	edges []dependencyEdge
+}
+
+// dependencyEdge is an edge from a target to one of its dependencies.
+type dependencyEdge struct {
+	from, to string
+	serial   bool
+}
+
+// newDependencyGraph builds the dependency graph by walking the bodies of the target functions in pkg
+// for calls to sg.Deps and sg.SerialDeps.
+//
+// Calls to package-local functions that are not targets, such as unexported helpers, are followed, so that the
+// dependencies passed to Deps in helpers are dependencies of the targets that call them.
+func newDependencyGraph(pkg *sagePackage, mks []Makefile) *dependencyGraph {
+	g := &dependencyGraph{}
+	seenNodes := map[string]bool{}
+	seenEdges := map[dependencyEdge]bool{}
+	addNode := func(node string) {
+		if !seenNodes[node] {
+			seenNodes[node] = true
+			g.nodes = append(g.nodes, node)
+		}
+	}
+	imports := map[*ast.FuncDecl]sageImport{}
+	for _, file := range pkg.files {
+		fileImport := newSageImport(file)
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+				imports[funcDecl] = fileImport
+			}
+		}
+	}
+	targets := map[string]string{} // target function name -> graph node
+	targetDecls := map[*ast.FuncDecl]bool{}
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		targetDecls[function.Decl] = true
+		if ok, _ := shouldBeGenerated(mks, function.Recv); ok {
+			targets[getTargetFunctionName(function)] = graphNodeName(function)
+		}
+	})
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		from, ok := targets[getTargetFunctionName(function)]
+		if !ok || function.Decl.Body == nil {
+			return
+		}
+		addNode(from)
+		visited := map[*ast.FuncDecl]bool{function.Decl: true}
+		var walk func(scope graphScope)
+		walk = func(scope graphScope) {
+			ast.Inspect(scope.decl.Body, func(node ast.Node) bool {
+				call, ok := node.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if ident, ok := call.Fun.(*ast.Ident); ok {
+					helper, ok := pkg.functions[pkg.info.Uses[ident]]
+					if ok && helper.decl.Body != nil && !targetDecls[helper.decl] && !visited[helper.decl] {
+						visited[helper.decl] = true
+						walk(graphScope{decl: helper.decl, sg: newSageImport(helper.file)})
+					}
+				}
+				if len(call.Args) < 2 {
+					return true
+				}
+				var serial bool
+				switch {
+				case scope.sg.isCall(call, "Deps"):
+				case scope.sg.isCall(call, "SerialDeps"):
+					serial = true
+				default:
+					return true
+				}
+				for _, arg := range call.Args[1:] {
+					to := resolveDependency(pkg, arg, scope, targets)
+					if to == "" {
+						continue
+					}
+					addNode(to)
+					if edge := (dependencyEdge{from: from, to: to, serial: serial}); !seenEdges[edge] {
+						seenEdges[edge] = true
+						g.edges = append(g.edges, edge)
+					}
+				}
+				return true
+			})
+		}
+		walk(graphScope{decl: function.Decl, recv: function.Recv, sg: imports[function.Decl]})
+	})
+	return g
+}
+
+// graphScope is a function that is walked for calls to Deps: a target function, or a package-local function that is
+// called by a target function.
+type graphScope struct {
+	decl *ast.FuncDecl
+	// recv is the namespace of a target function that is a method.
+	recv string
+	// sg is the import of the sg package in the file of the function.
+	sg sageImport
+}
+
+// graphNodeName returns the name of a target in the dependency graph, which is its Make target
+// prefixed by the namespace for namespaced targets.
+func graphNodeName(function *doc.Func) string {
+	if function.Recv != "" {
+		return toMakeTarget(function.Recv) + ":" + effectiveMakeTarget(function)
+	}
+	return effectiveMakeTarget(function)
+}
+
+// sageImport is the import of the sg package in a file.
+type sageImport struct {
+	// names are the names that the sg package is imported as.
+	names []string
+	// dot is true if the sg package is dot-imported.
+	dot bool
+}
+
+// newSageImport returns the import of the sg package in file.
+func newSageImport(file *ast.File) sageImport {
+	var result sageImport
+	for _, spec := range file.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err != nil || importPath != "go.einride.tech/sage/sg" {
+			continue
+		}
+		switch {
+		case spec.Name == nil:
+			result.names = append(result.names, "sg")
+		case spec.Name.Name == ".":
+			result.dot = true
+		case spec.Name.Name != "_":
+			result.names = append(result.names, spec.Name.Name)
+		}
+	}
+	return result
+}
+
+// isCall returns true if call is a call to the sg function with the provided name.
+func (i sageImport) isCall(call *ast.CallExpr, name string) bool {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return i.dot && fun.Name == name
+	case *ast.SelectorExpr:
+		ident, ok := fun.X.(*ast.Ident)
+		return ok && slices.Contains(i.names, ident.Name) && fun.Sel.Name == name
+	}
+	return false
+}
+
+// resolveDependency returns the graph node of a dependency expression passed to Deps in scope.
+// Dependencies outside of the sagefile are named by their Go expression, and unresolvable
+// dependencies, such as variables, are returned as empty strings.
+func resolveDependency(pkg *sagePackage, expr ast.Expr, scope graphScope, targets map[string]string) string {
+	switch expr := expr.(type) {
+	case *ast.CallExpr:
+		if scope.sg.isCall(expr, "Fn") && len(expr.Args) > 0 {
+			return resolveDependency(pkg, expr.Args[0], scope, targets)
+		}
+	case *ast.Ident:
+		if node, ok := targets[expr.Name]; ok {
+			return node
+		}
+	case *ast.SelectorExpr:
+		// Resolve method values on namespace values, e.g. sg.Deps(ctx, n.Target) or sg.Deps(ctx, Proto{}.Target).
+		if named, ok := types.Unalias(derefType(pkg.typeOf(expr.X))).(*types.Named); ok {
+			if node, ok := targets[named.Obj().Name()+":"+expr.Sel.Name]; ok {
+				return node
+			}
+		}
+		ident, ok := expr.X.(*ast.Ident)
+		if !ok {
+			return ""
+		}
+		namespace := ident.Name
+		// Resolve method values on the receiver when the type of the receiver is not known.
+		if scope.recv != "" && receiverName(scope.decl) == ident.Name {
+			namespace = scope.recv
+		}
+		if node, ok := targets[namespace+":"+expr.Sel.Name]; ok {
+			return node
+		}
+		return ident.Name + "." + expr.Sel.Name
+	}
+	return ""
+}
+
+// derefType returns the element type of t if it is a pointer type, and t otherwise.
+func derefType(t types.Type) types.Type {
+	if pointer, ok := t.(*types.Pointer); ok {
+		return pointer.Elem()
+	}
+	return t
+}
+
+func receiverName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 || len(decl.Recv.List[0].Names) == 0 {
+		return ""
+	}
+	return decl.Recv.List[0].Names[0].Name
+}
+
+// writeDOT writes the graph in the Graphviz DOT language.
+func (g *dependencyGraph) writeDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph sage {"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "\trankdir=LR;"); err != nil {
+		return err
+	}
+	for _, node := range g.nodes {
+		if _, err := fmt.Fprintf(w, "\t%s;\n", strconv.Quote(node)); err != nil {
+			return err
+		}
+	}
+	for _, edge := range g.edges {
+		var attrs string
+		if edge.serial {
+			attrs = ` [style=dashed, label="serial"]`
+		}
+		if _, err := fmt.Fprintf(w, "\t%s -> %s%s;\n", strconv.Quote(edge.from), strconv.Quote(edge.to), attrs); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// writeMermaid writes the graph as a Mermaid flowchart.
+func (g *dependencyGraph) writeMermaid(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "graph LR"); err != nil {
+		return err
+	}
+	ids := make(map[string]string, len(g.nodes))
+	for i, node := range g.nodes {
+		ids[node] = fmt.Sprintf("n%d", i)
+		if _, err := fmt.Fprintf(w, "\t%s[%s]\n", ids[node], strconv.Quote(node)); err != nil {
+			return err
+		}
+	}
+	for _, edge := range g.edges {
+		arrow := "-->"
+		if edge.serial {
+			arrow = "-. serial .->"
+		}
+		if _, err := fmt.Fprintf(w, "\t%s %s %s\n", ids[edge.from], arrow, ids[edge.to]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
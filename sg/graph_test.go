@@ -0,0 +1,177 @@
+package sg
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+const graphTestSagefile = `package main
+
+import (
+	"context"
+
+	"go.einride.tech/sage/sg"
+)
+
+type Proto sg.Namespace
+
+func Default(ctx context.Context) error {
+	sg.Deps(ctx, GoLint, sg.Fn(ConvcoCheck, "origin/main..HEAD"), Proto.Generate)
+	sg.SerialDeps(ctx, GoModTidy)
+	return nil
+}
+
+func GoLint(ctx context.Context) error { return nil }
+
+func GoModTidy(ctx context.Context) error { return nil }
+
+func ConvcoCheck(ctx context.Context, rev string) error { return nil }
+
+func (p Proto) Generate(ctx context.Context) error {
+	sg.Deps(ctx, p.Lint)
+	return nil
+}
+
+func (Proto) Lint(ctx context.Context) error { return nil }
+`
+
+func TestDependencyGraph(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, graphTestSagefile)
+	graph := newDependencyGraph(pkg, []Makefile{{}, {Namespace: Proto{}}})
+	var dot bytes.Buffer
+	if err := graph.writeDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	const expected = `digraph sage {
+	rankdir=LR;
+	"convco-check";
+	"default";
+	"go-lint";
+	"proto:generate";
+	"go-mod-tidy";
+	"proto:lint";
+	"default" -> "go-lint";
+	"default" -> "convco-check";
+	"default" -> "proto:generate";
+	"default" -> "go-mod-tidy" [style=dashed, label="serial"];
+	"proto:generate" -> "proto:lint";
+}
+`
+	if dot.String() != expected {
+		t.Errorf("expected DOT:\n%s\ngot:\n%s", expected, dot.String())
+	}
+}
+
+type Proto Namespace
+
+func TestDependencyGraph_ImportNames(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(
+		t,
+		`package main
+
+import (
+	"context"
+
+	sage "go.einride.tech/sage/sg"
+)
+
+func Default(ctx context.Context) error {
+	sage.Deps(ctx, Build, sage.Fn(Test, "./..."))
+	return nil
+}
+
+func Build(ctx context.Context) error { return nil }
+`,
+		`package main
+
+import (
+	"context"
+
+	. "go.einride.tech/sage/sg"
+)
+
+func Test(ctx context.Context, pkg string) error {
+	SerialDeps(ctx, Build)
+	return nil
+}
+`,
+		`package main
+
+import (
+	"context"
+
+	sg "example.com/other/sg"
+)
+
+func Lint(ctx context.Context) error {
+	sg.Deps(ctx, Build)
+	return nil
+}
+`,
+	)
+	graph := newDependencyGraph(pkg, []Makefile{{}})
+	expected := []dependencyEdge{
+		{from: "default", to: "build"},
+		{from: "default", to: "test"},
+		{from: "test", to: "build", serial: true},
+	}
+	if !slices.Equal(graph.edges, expected) {
+		t.Errorf("expected edges %v, got %v", expected, graph.edges)
+	}
+}
+
+func TestDependencyGraph_Helpers(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, `package main
+
+import (
+	"context"
+
+	"go.einride.tech/sage/sg"
+)
+
+type Proto sg.Namespace
+
+func Default(ctx context.Context) error {
+	lint(ctx)
+	return nil
+}
+
+func Build(ctx context.Context) error {
+	var p Proto
+	sg.Deps(ctx, sg.Fn(p.Generate, "api"), Proto{}.Lint)
+	return Default(ctx)
+}
+
+func lint(ctx context.Context) {
+	sg.Deps(ctx, GoLint)
+	generate(ctx)
+}
+
+func generate(ctx context.Context) {
+	sg.SerialDeps(ctx, Proto.Generate)
+	lint(ctx)
+}
+
+func GoLint(ctx context.Context) error { return nil }
+
+func (Proto) Generate(ctx context.Context, dir string) error { return nil }
+
+func (Proto) Lint(ctx context.Context) error { return nil }
+`)
+	graph := newDependencyGraph(pkg, []Makefile{{}, {Namespace: Proto{}}})
+	// Dependencies of helpers are dependencies of the targets calling them, but not of the targets calling those
+	// targets.
+	expected := []dependencyEdge{
+		{from: "build", to: "proto:generate"},
+		{from: "build", to: "proto:lint"},
+		{from: "default", to: "go-lint"},
+		{from: "default", to: "proto:generate", serial: true},
+	}
+	if !slices.Equal(graph.edges, expected) {
+		t.Errorf("expected edges %v, got %v", expected, graph.edges)
+	}
+}
@@ -0,0 +1,111 @@
+package sg
+
+import (
+	"fmt"
+	"go/doc"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// helpTarget is a Make target in the generated help.
+type helpTarget struct {
+	name        string
+	vars        []string
+	group       string
+	synopsis    string
+	defaultGoal bool
+}
+
+// helpTargets returns the Make targets generated for the Makefile mk, in the order they appear in the Makefile.
+func helpTargets(pkg *sagePackage, mk Makefile) []helpTarget {
+	var result []helpTarget
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if function.Recv != mk.namespaceName() || isHiddenTarget(function) {
+			return
+		}
+		target := helpTarget{
+			name:        effectiveMakeTarget(function),
+			group:       getTargetGroup(function),
+			synopsis:    pkg.Synopsis(function.Doc),
+			defaultGoal: function.Name == mk.defaultTargetName(),
+		}
+		defaults := getTargetDefaults(function)
+		for _, makeVar := range toMakeVars(function.Decl.Type.Params.List[1:]) {
+			if value, ok := defaults[makeVar]; ok {
+				target.vars = append(target.vars, fmt.Sprintf("[%s=%s]", makeVar, value))
+			} else {
+				target.vars = append(target.vars, makeVar+"=...")
+			}
+		}
+		result = append(result, target)
+	})
+	return result
+}
+
+// writeHelp writes the help for the Makefile mk to w.
+// The help for the root Makefile also lists the targets of the namespace Makefiles, grouped by namespace.
+func writeHelp(w io.Writer, pkg *sagePackage, mk Makefile, mks []Makefile) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "Usage: make [target] [variable=value ...]")
+	_, _ = fmt.Fprintln(tw)
+	_, _ = fmt.Fprintln(tw, "Targets:")
+	writeHelpTargets(tw, helpTargets(pkg, mk))
+	if mk.namespaceName() == "" {
+		for _, namespaceMk := range mks {
+			if namespaceMk.namespaceName() == "" || namespaceMk.Path == "" {
+				continue
+			}
+			mkPath, err := filepath.Rel(FromGitRoot(), filepath.Dir(namespaceMk.Path))
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(tw)
+			_, _ = fmt.Fprintf(tw, "%s (make -C %s):\n", namespaceMk.namespaceName(), mkPath)
+			writeHelpTargets(tw, helpTargets(pkg, namespaceMk))
+		}
+	}
+	_, _ = fmt.Fprintln(tw)
+	_, _ = fmt.Fprintln(tw, "Sage targets: help sage update-sage sage-check sage-graph watch clean-sage")
+	return tw.Flush()
+}
+
+// writeHelpTargets writes targets without a group first, followed by the targets of each group under a heading.
+func writeHelpTargets(w io.Writer, targets []helpTarget) {
+	var groups []string
+	for _, target := range targets {
+		if target.group == "" {
+			writeHelpTarget(w, "  ", target)
+		} else if !slices.Contains(groups, target.group) {
+			groups = append(groups, target.group)
+		}
+	}
+	for _, group := range groups {
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintf(w, "  %s:\n", group)
+		for _, target := range targets {
+			if target.group == group {
+				writeHelpTarget(w, "    ", target)
+			}
+		}
+	}
+}
+
+func writeHelpTarget(w io.Writer, indent string, target helpTarget) {
+	name := target.name
+	if target.defaultGoal {
+		name += " (default)"
+	}
+	_, _ = fmt.Fprintf(w, "%s%s\t%s\t%s\n", indent, name, strings.Join(target.vars, " "), target.synopsis)
+}
+
+// helpText returns the help for the Makefile mk.
+func helpText(pkg *sagePackage, mk Makefile, mks []Makefile) string {
+	var b strings.Builder
+	if err := writeHelp(&b, pkg, mk, mks); err != nil {
+		panic(err)
+	}
+	return b.String()
+}
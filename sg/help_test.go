@@ -0,0 +1,72 @@
+package sg
+
+import "testing"
+
+const helpTestSagefile = `package main
+
+import (
+	"context"
+
+	"go.einride.tech/sage/sg"
+)
+
+type Proto sg.Namespace
+
+// Default runs all targets. It is the default goal.
+func Default(ctx context.Context) error { return nil }
+
+// Deploy deploys the service to env.
+//
+//sage:default env=dev
+func Deploy(ctx context.Context, service string, env string) error { return nil }
+
+// Generate generates code from the protobuf schemas.
+//
+//sage:group codegen
+func (Proto) Generate(ctx context.Context) error { return nil }
+
+// Lint lints the protobuf schemas.
+func (Proto) Lint(ctx context.Context) error { return nil }
+
+// Build builds the service.
+//
+//sage:group build
+func Build(ctx context.Context) error { return nil }
+
+// BuildImage builds the image of the service.
+//
+//sage:group build
+func BuildImage(ctx context.Context) error { return nil }
+
+// Helper is only run with Deps.
+//
+//sage:hidden
+func Helper(ctx context.Context) error { return nil }
+`
+
+func TestWriteHelp(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, helpTestSagefile)
+	mks := []Makefile{{}, {Path: FromGitRoot("proto", "Makefile"), Namespace: Proto{}}}
+	const expected = `Usage: make [target] [variable=value ...]
+
+Targets:
+  default                         Default runs all targets.
+  deploy   service=... [env=dev]  Deploy deploys the service to env.
+
+  build:
+    build          Build builds the service.
+    build-image    BuildImage builds the image of the service.
+
+Proto (make -C proto):
+  lint    Lint lints the protobuf schemas.
+
+  codegen:
+    generate    Generate generates code from the protobuf schemas.
+
+Sage targets: help sage update-sage sage-check sage-graph watch clean-sage
+`
+	if got := helpText(pkg, mks[0], mks); got != expected {
+		t.Errorf("expected help\n%s\nbut got\n%s", expected, got)
+	}
+}
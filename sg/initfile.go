@@ -1,10 +1,14 @@
 package sg
 
 import (
+	"encoding"
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/doc"
+	"go/types"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -17,27 +21,53 @@ const (
 	boolType   = "bool"
 )
 
-func generateInitFile(g *codegen.File, pkg *doc.Package, mks []Makefile) error {
+func generateInitFile(g *codegen.File, pkg *sagePackage, mks []Makefile) error {
 	g.P("func init() {")
 	g.P("ctx := ", g.Import("context"), ".Background()")
-	g.P("if len(", g.Import("os"), ".Args) < 2 {")
-	g.P(g.Import("fmt"), `.Println("Targets:")`)
-	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
-		// If function namespace is not part of the to be generated Makefiles, skip it.
-		if skipFunction, _ := shouldBeGenerated(mks, function.Recv); !skipFunction {
-			return
+	g.P("if len(", g.Import("os"), ".Args) < 2 || ", g.Import("os"), `.Args[1] == "help" {`)
+	g.P("var namespace string")
+	g.P("if len(", g.Import("os"), ".Args) > 2 {")
+	g.P("namespace = ", g.Import("os"), ".Args[2]")
+	g.P("}")
+	g.P("switch namespace {")
+	var rootMk Makefile
+	for _, mk := range mks {
+		if mk.namespaceName() == "" {
+			rootMk = mk
+			continue
 		}
-		g.P(g.Import("fmt"), `.Println("\t`, getTargetFunctionName(function), `")`)
-	})
+		g.P("case ", strconv.Quote(mk.namespaceName()), ":")
+		g.P(g.Import("fmt"), ".Print(", strconv.Quote(helpText(pkg, mk, mks)), ")")
+	}
+	g.P("default:")
+	g.P(g.Import("fmt"), ".Print(", strconv.Quote(helpText(pkg, rootMk, mks)), ")")
+	g.P("}")
 	g.P(g.Import("os"), ".Exit(0)")
 	g.P("}")
 	g.P("target, args := ", g.Import("os"), ".Args[1], ", g.Import("os"), ".Args[2:]")
+	g.P(`if target == "watch" && len(args) > 0 {`)
+	g.P("ctx = ", g.Import("go.einride.tech/sage/sg"), ".WithWatch(ctx)")
+	g.P("target, args = args[0], args[1:]")
+	g.P("}")
+	targetsJSON, err := json.Marshal(cliTargets(pkg, mks))
+	if err != nil {
+		return err
+	}
+	g.P(
+		`if target == "run" || target == "list" || target == "-h" || target == "--help" || target == `,
+		strconv.Quote(completionCommand), " || target == ", strconv.Quote(completeCommand), " {",
+	)
+	g.P(
+		"target, args = ", g.Import("go.einride.tech/sage/sg"), ".ParseCommandLine(",
+		strconv.Quote(string(targetsJSON)), ", target, args)",
+	)
+	g.P("}")
 	g.P("_ = args")
 	g.P("var err error")
 	g.P("switch target {")
 	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
 		// If function namespace is not part of the to be generated Makefiles, skip it.
-		skipFunction, nsStruct := shouldBeGenerated(mks, function.Recv)
+		skipFunction, namespace := shouldBeGenerated(mks, function.Recv)
 		if !skipFunction {
 			return
 		}
@@ -61,51 +91,82 @@ func generateInitFile(g *codegen.File, pkg *doc.Package, mks []Makefile) error {
 		g.P("<-shutdownCh")
 		g.P("cancel()")
 		g.P("}()")
+		call := function.Name
+		if function.Recv != "" {
+			g.P("namespace := ", goLiteral(g, namespace))
+			if len(namespaceVars(namespace)) > 0 {
+				g.P("args, err = ", g.Import("go.einride.tech/sage/sg"), ".SetNamespaceFields(&namespace, args)")
+				g.P("if err != nil {")
+				g.P("logger.Print(err)")
+				g.P(g.Import("os"), ".Exit(", usageExitCode, ")")
+				g.P("}")
+			}
+			call = "namespace." + function.Name
+		}
 		if len(function.Decl.Type.Params.List) > 1 {
 			expected := countParams(function.Decl.Type.Params.List) - 1
-			g.P("if len(args) != ", expected, " {")
-			g.P(
-				`logger.Fatalf("wrong number of arguments to %s, got %v expected %v",`,
-				strconv.Quote(getTargetFunctionName(function)), ",",
-				`len(args)`, ",",
-				expected, `)`,
-			)
-			g.P(g.Import("os"), ".Exit(1)")
+			makeVars := toMakeVars(function.Decl.Type.Params.List[1:])
+			defaults := getTargetDefaults(function)
+			// Trailing arguments with default values may be omitted.
+			required := expected
+			for required > 0 {
+				if _, ok := defaults[makeVars[required-1]]; !ok {
+					break
+				}
+				required--
+			}
+			if required == expected {
+				g.P("if len(args) != ", expected, " {")
+				g.P(
+					`logger.Printf("wrong number of arguments to %s, got %v expected %v",`,
+					strconv.Quote(getTargetFunctionName(function)), ",",
+					`len(args)`, ",",
+					expected, `)`,
+				)
+			} else {
+				g.P("if len(args) < ", required, " || len(args) > ", expected, " {")
+				g.P(
+					`logger.Printf("wrong number of arguments to %s, got %v expected %v to %v",`,
+					strconv.Quote(getTargetFunctionName(function)), ",",
+					`len(args)`, ",",
+					required, ",",
+					expected, `)`,
+				)
+			}
+			g.P(g.Import("os"), ".Exit(", usageExitCode, ")")
 			g.P("}")
+			for i := required; i < expected; i++ {
+				g.P("if len(args) == ", i, " {")
+				g.P("args = append(args, ", strconv.Quote(defaults[makeVars[i]]), ")")
+				g.P("}")
+			}
 			var args []string
 			var i int
 			for _, customParam := range function.Decl.Type.Params.List[1:] {
 				for range customParam.Names {
 					args = append(args, fmt.Sprintf("arg%v", i))
-					switch fmt.Sprint(customParam.Type) {
-					case stringType:
-						g.P("arg", i, " := args[", i, "]")
-					case intType:
-						g.P("arg", i, ", err :=", g.Import("strconv"), ".Atoi(args[", i, "])")
-						g.P("if err != nil {")
-						g.P(`logger.Fatalf("can't convert argument %q to int", args[`, i, `])`)
-						g.P("}")
-					case boolType:
-						g.P("arg", i, ", err :=", g.Import("strconv"), ".ParseBool(args[", i, "])")
-						g.P("if err != nil {")
-						g.P(`logger.Fatalf("can't convert argument to bool", args[`, i, `])`)
-						g.P("}")
-					}
+					generateParamParser(g, pkg, customParam.Type, i, makeVars[i])
 					i++
 				}
 			}
 			g.P(
-				"err = ",
-				strings.ReplaceAll(getTargetFunctionName(function), ":", nsStruct),
-				"(ctx,",
-				strings.Join(args, ","),
-				")",
+				"err = ", g.Import("go.einride.tech/sage/sg"), ".RunMain(ctx, ",
+				strconv.Quote(getTargetRuntimeName(function, namespace)), ", ",
+				"func(ctx ", g.Import("context"), ".Context) error {",
 			)
+			g.P("return ", call, "(ctx,", strings.Join(args, ","), ")")
+			g.P("})")
 			g.P("if err != nil {")
 			g.P("logger.Fatal(err)")
 			g.P("}")
 		} else {
-			g.P("err = ", strings.ReplaceAll(getTargetFunctionName(function), ":", nsStruct), "(ctx)")
+			g.P(
+				"err = ", g.Import("go.einride.tech/sage/sg"), ".RunMain(ctx, ",
+				strconv.Quote(getTargetRuntimeName(function, namespace)), ", ",
+				"func(ctx ", g.Import("context"), ".Context) error {",
+			)
+			g.P("return ", call, "(ctx)")
+			g.P("})")
 			g.P("if err != nil {")
 			g.P("logger.Fatal(err)")
 			g.P("}")
@@ -113,7 +174,8 @@ func generateInitFile(g *codegen.File, pkg *doc.Package, mks []Makefile) error {
 	})
 	g.P("default:")
 	g.P("logger := ", g.Import("go.einride.tech/sage/sg"), ".NewLogger(\"sagefile\")")
-	g.P(`logger.Fatalf("unknown target specified: %s", target)`)
+	g.P(`logger.Printf("unknown target specified: %s", target)`)
+	g.P(g.Import("os"), ".Exit(", usageExitCode, ")")
 	g.P("}")
 	g.P(g.Import("os"), ".Exit(0)")
 	g.P("}")
@@ -121,33 +183,95 @@ func generateInitFile(g *codegen.File, pkg *doc.Package, mks []Makefile) error {
 }
 
 // shouldBeGenerated returns true if the namespace equals any of the namespaces in the to be generated Makefiles and
-// returns any metadata the namespace might have.
-func shouldBeGenerated(mks []Makefile, namespace string) (bool, string) {
-	var partOfMakefile bool
-	namespaceStruct := "{"
+// returns the namespace value of the first of them.
+func shouldBeGenerated(mks []Makefile, namespace string) (bool, reflect.Value) {
 	for _, mk := range mks {
 		if mk.namespaceName() == namespace {
-			partOfMakefile = true
-			val := reflect.Indirect(reflect.ValueOf(mk.Namespace))
-			if !reflect.Value.IsValid(val) {
+			return true, reflect.ValueOf(mk.Namespace)
+		}
+	}
+	return false, reflect.Value{}
+}
+
+// goLiteral returns a Go expression of the value v, with the imports it needs added to the generated file g.
+func goLiteral(g *codegen.File, v reflect.Value) string {
+	t := v.Type()
+	switch t.Kind() {
+	case reflect.Struct:
+		if v.IsZero() {
+			return goTypeName(g, t) + "{}"
+		}
+		// Structs such as time.Time and netip.Addr have unexported fields, but can be parsed back from their text.
+		if t.Implements(reflect.TypeFor[encoding.TextMarshaler]()) && isTextUnmarshaler(t) {
+			return goUnmarshalText(g, v)
+		}
+		fields := make([]string, 0, t.NumField())
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if v.Field(i).IsZero() {
 				continue
 			}
-			for i := 1; i < val.NumField(); i++ {
-				namespaceStruct = fmt.Sprintf("%s\n%s:", namespaceStruct, val.Type().Field(i).Name)
-				fieldValue := reflect.ValueOf(val.Field(i).Interface())
-				switch val.Field(i).Kind() {
-				case reflect.String:
-					namespaceStruct = fmt.Sprintf(`%s "%v",`, namespaceStruct, fieldValue)
-				case reflect.Bool, reflect.Int:
-					namespaceStruct = fmt.Sprintf("%s %v,", namespaceStruct, fieldValue)
-				default:
-					panic("unsupported type for namespace field")
-				}
+			if !field.IsExported() {
+				panic(fmt.Sprintf("unsupported unexported namespace field %s.%s", t, field.Name))
 			}
+			fields = append(fields, field.Name+": "+goLiteral(g, v.Field(i)))
+		}
+		return goTypeName(g, t) + "{" + strings.Join(fields, ", ") + "}"
+	case reflect.Slice:
+		if v.IsNil() {
+			return "nil"
+		}
+		elems := make([]string, 0, v.Len())
+		for i := range v.Len() {
+			elems = append(elems, goLiteral(g, v.Index(i)))
 		}
+		return goTypeName(g, t) + "{" + strings.Join(elems, ", ") + "}"
+	case reflect.String:
+		return goConversion(g, t, strconv.Quote(v.String()))
+	case reflect.Bool:
+		return goConversion(g, t, strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return goConversion(g, t, strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return goConversion(g, t, strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		return goConversion(g, t, strconv.FormatFloat(v.Float(), 'g', -1, t.Bits()))
+	default:
+		panic(fmt.Sprintf("unsupported type %s for namespace field", t))
+	}
+}
+
+// goUnmarshalText returns an expression that parses the text of the encoding.TextMarshaler v into a value of its type.
+func goUnmarshalText(g *codegen.File, v reflect.Value) string {
+	text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		panic(fmt.Sprintf("failed to marshal namespace field of type %s: %v", v.Type(), err))
+	}
+	return "func() (v " + goTypeName(g, v.Type()) + ") {" +
+		"if err := v.UnmarshalText([]byte(" + strconv.Quote(string(text)) + ")); err != nil { panic(err) }; " +
+		"return v }()"
+}
+
+// goConversion returns the untyped constant expression converted to the type t, unless t is a predeclared type.
+func goConversion(g *codegen.File, t reflect.Type, expr string) string {
+	if t.PkgPath() == "" {
+		return expr
+	}
+	return goTypeName(g, t) + "(" + expr + ")"
+}
+
+// goTypeName returns the name of the type t in the generated file g.
+func goTypeName(g *codegen.File, t reflect.Type) string {
+	switch {
+	case t.Name() == "" && t.Kind() == reflect.Slice:
+		return "[]" + goTypeName(g, t.Elem())
+	case t.Name() == "":
+		panic(fmt.Sprintf("unsupported type %s for namespace field", t))
+	case t.PkgPath() == "" || t.PkgPath() == "main":
+		return t.Name()
+	default:
+		return g.Import(t.PkgPath()) + "." + t.Name()
 	}
-	namespaceStruct += "}."
-	return partOfMakefile, namespaceStruct
 }
 
 func countParams(fields []*ast.Field) int {
@@ -168,11 +292,21 @@ func getTargetFunctionName(function *doc.Func) string {
 	return result.String()
 }
 
-func forEachTargetFunction(pkg *doc.Package, fn func(function *doc.Func, namespace *doc.Type)) {
+// getTargetRuntimeName returns the name that the Go runtime reports for a target function,
+// matching the names of targets created with Fn. The namespace is invalid for functions without a receiver.
+func getTargetRuntimeName(function *doc.Func, namespace reflect.Value) string {
+	pkgPath := "main"
+	if namespace.IsValid() && namespace.Type().PkgPath() != "" {
+		pkgPath = namespace.Type().PkgPath()
+	}
+	return pkgPath + "." + strings.ReplaceAll(getTargetFunctionName(function), ":", ".")
+}
+
+func forEachTargetFunction(pkg *sagePackage, fn func(function *doc.Func, namespace *doc.Type)) {
 	for _, function := range pkg.Funcs {
 		if function.Recv != "" ||
 			!ast.IsExported(function.Name) ||
-			!isSupportedTargetFunctionParams(function.Decl.Type.Params.List) {
+			!isSupportedTargetFunctionParams(pkg, function.Decl.Type.Params.List) {
 			continue
 		}
 		fn(function, nil)
@@ -183,7 +317,7 @@ func forEachTargetFunction(pkg *doc.Package, fn func(function *doc.Func, namespa
 		}
 		for _, function := range namespace.Methods {
 			if !ast.IsExported(function.Name) ||
-				!isSupportedTargetFunctionParams(function.Decl.Type.Params.List) {
+				!isSupportedTargetFunctionParams(pkg, function.Decl.Type.Params.List) {
 				continue
 			}
 			fn(function, nil)
@@ -191,31 +325,81 @@ func forEachTargetFunction(pkg *doc.Package, fn func(function *doc.Func, namespa
 	}
 }
 
-func isSupportedTargetFunctionParams(params []*ast.Field) bool {
+func isSupportedTargetFunctionParams(pkg *sagePackage, params []*ast.Field) bool {
 	if len(params) == 0 {
 		return false
 	}
-	if !isContextParam(params[0]) {
+	if !isContextParam(pkg, params[0]) {
 		return false
 	}
 	for _, customParam := range params[1:] {
-		if !isSupportedCustomParam(customParam) {
+		if !isSupportedCustomParam(pkg, customParam) {
 			return false
 		}
 	}
 	return true
 }
 
-func isSupportedCustomParam(arg *ast.Field) bool {
-	switch fmt.Sprint(arg.Type) {
-	case stringType, intType, boolType:
-		return true
-	default:
-		return false
+func isSupportedCustomParam(pkg *sagePackage, arg *ast.Field) bool {
+	return customParamKind(pkg, arg.Type) != unsupportedParam
+}
+
+// isTargetFunctionCandidate returns true if function is exported and takes a context.Context as its first parameter,
+// which makes it a target if the types of its other parameters are supported.
+func isTargetFunctionCandidate(pkg *sagePackage, function *doc.Func) bool {
+	params := function.Decl.Type.Params.List
+	return ast.IsExported(function.Name) && len(params) > 0 && isContextParam(pkg, params[0])
+}
+
+// unsupportedCustomParam returns the first of the custom params with a type that is not supported, or nil.
+func unsupportedCustomParam(pkg *sagePackage, params []*ast.Field) *ast.Field {
+	for _, param := range params {
+		if !isSupportedCustomParam(pkg, param) {
+			return param
+		}
 	}
+	return nil
 }
 
-func isContextParam(param *ast.Field) bool {
+// unsupportedParamReason returns the reason that the type of the custom param is not supported.
+func unsupportedParamReason(pkg *sagePackage, param *ast.Field) string {
+	if err := pkg.importError(param.Type); err != nil {
+		return "unresolved parameter type " + types.ExprString(param.Type) + ": " + err.Error()
+	}
+	return "unsupported parameter type " + types.ExprString(param.Type)
+}
+
+// skippedTargets returns a message for each target function candidate in pkg that is not a target, because the type of
+// one of its parameters is not supported.
+func skippedTargets(pkg *sagePackage) []string {
+	result := slices.Clone(pkg.skipped)
+	check := func(function *doc.Func, name string) {
+		if !isTargetFunctionCandidate(pkg, function) {
+			return
+		}
+		if param := unsupportedCustomParam(pkg, function.Decl.Type.Params.List[1:]); param != nil {
+			result = append(result, name+": "+unsupportedParamReason(pkg, param))
+		}
+	}
+	for _, function := range pkg.Funcs {
+		check(function, function.Name)
+	}
+	for _, namespace := range pkg.Types {
+		if ast.IsExported(namespace.Name) && isNamespace(namespace) {
+			for _, function := range namespace.Methods {
+				check(function, namespace.Name+"."+function.Name)
+			}
+		}
+	}
+	return result
+}
+
+// isContextParam returns true if the type of param is context.Context, including through a renamed import, a dot
+// import or a type alias. If the type is not known, the type must be written as context.Context.
+func isContextParam(pkg *sagePackage, param *ast.Field) bool {
+	if t := pkg.typeOf(param.Type); t != nil {
+		return isContextType(t)
+	}
 	selectorExpr, ok := param.Type.(*ast.SelectorExpr)
 	if !ok {
 		return false
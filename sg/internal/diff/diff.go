@@ -0,0 +1,166 @@
+// Package diff provides unified diffs of text files.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around changes.
+const context = 3
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	// line is the index of the line in the old text for equal and deleted lines, and in the new text for inserted lines.
+	line int
+}
+
+// Unified returns a unified diff of the texts oldText and newText with the names oldName and newName.
+// Returns nil if the texts are equal.
+func Unified(oldName, newName string, oldText, newText []byte) []byte {
+	if bytes.Equal(oldText, newText) {
+		return nil
+	}
+	oldLines, newLines := splitLines(oldText), splitLines(newText)
+	ops := diffLines(oldLines, newLines)
+	var b bytes.Buffer
+	_, _ = fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change and the end of its hunk.
+		for start < len(ops) && ops[start].kind == opEqual {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		hunkStart := max(start-context, 0)
+		end := start
+		for equal := 0; end < len(ops) && equal <= 2*context; end++ {
+			if ops[end].kind == opEqual {
+				equal++
+			} else {
+				equal = 0
+			}
+		}
+		hunkEnd := end
+		for hunkEnd > start && ops[hunkEnd-1].kind == opEqual {
+			hunkEnd--
+		}
+		hunkEnd = min(hunkEnd+context, len(ops))
+		writeHunk(&b, ops[hunkStart:hunkEnd], oldLines, newLines, oldLineNumber(ops, hunkStart), newLineNumber(ops, hunkStart))
+		start = hunkEnd
+	}
+	return b.Bytes()
+}
+
+func writeHunk(b *bytes.Buffer, ops []op, oldLines, newLines []string, oldStart, newStart int) {
+	var oldCount, newCount int
+	for _, o := range ops {
+		if o.kind != opInsert {
+			oldCount++
+		}
+		if o.kind != opDelete {
+			newCount++
+		}
+	}
+	_, _ = fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+	for _, o := range ops {
+		line := oldLines
+		if o.kind == opInsert {
+			line = newLines
+		}
+		_ = b.WriteByte(byte(o.kind))
+		_, _ = b.WriteString(line[o.line])
+		if !strings.HasSuffix(line[o.line], "\n") {
+			_, _ = b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range starts at the line before the hunk.
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// oldLineNumber returns the index in the old text of the line at ops[i].
+func oldLineNumber(ops []op, i int) int {
+	var n int
+	for _, o := range ops[:i] {
+		if o.kind != opInsert {
+			n++
+		}
+	}
+	return n
+}
+
+// newLineNumber returns the index in the new text of the line at ops[i].
+func newLineNumber(ops []op, i int) int {
+	var n int
+	for _, o := range ops[:i] {
+		if o.kind != opDelete {
+			n++
+		}
+	}
+	return n
+}
+
+// diffLines returns the edit script from a to b, based on their longest common subsequence of lines.
+func diffLines(a, b []string) []op {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	ops := make([]op, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{kind: opEqual, line: i})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{kind: opDelete, line: i})
+			i++
+		default:
+			ops = append(ops, op{kind: opInsert, line: j})
+			j++
+		}
+	}
+	return ops
+}
+
+func splitLines(text []byte) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(text), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,50 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name: "equal",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+		},
+		{
+			name:     "changed line",
+			old:      "a\nb\nc\nd\ne\nf\ng\nh\n",
+			new:      "a\nb\nc\nd\nE\nf\ng\nh\n",
+			expected: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n",
+		},
+		{
+			name:     "separate hunks",
+			old:      "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:      "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n12\n",
+			expected: "--- old\n+++ new\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -8,5 +9,4 @@\n 8\n 9\n 10\n-11\n 12\n",
+		},
+		{
+			name:     "new file",
+			old:      "",
+			new:      "a\n",
+			expected: "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name:     "no newline at end of file",
+			old:      "a\nb",
+			new:      "a\nb\n",
+			expected: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := string(Unified("old", "new", []byte(tt.old), []byte(tt.new))); got != tt.expected {
+				t.Errorf("expected\n%s\nbut got\n%s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -10,28 +10,36 @@ import (
 //nolint:gochecknoglobals
 var (
 	mu      sync.Mutex
-	onceFns = map[string]func(context.Context) error{}
+	onceFns = map[string]*onceFn{}
 )
 
+type onceFn struct {
+	done chan struct{}
+	err  error
+}
+
 // RunOnce uses key to ensure that fn runs exactly once and always returns the error from the initial run.
+//
+// If the initial run fails after its context has been cancelled, the result is not cached and the next call to
+// RunOnce with the same key runs fn again.
 func RunOnce(ctx context.Context, key string, fn func(context.Context) error) error {
 	mu.Lock()
-	onceFn, ok := onceFns[key]
-	if !ok {
-		onceFn = makeOnceFn(fn)
-		onceFns[key] = onceFn
+	if f, ok := onceFns[key]; ok {
+		mu.Unlock()
+		<-f.done
+		return f.err
 	}
+	f := &onceFn{done: make(chan struct{})}
+	onceFns[key] = f
 	mu.Unlock()
-	return onceFn(ctx)
-}
-
-func makeOnceFn(fn func(context.Context) error) func(context.Context) error {
-	var once sync.Once
-	var err error
-	return func(ctx context.Context) error {
-		once.Do(func() {
-			err = fn(ctx)
-		})
-		return err
-	}
+	defer close(f.done)
+	defer func() {
+		if f.err != nil && ctx.Err() != nil {
+			mu.Lock()
+			delete(onceFns, key)
+			mu.Unlock()
+		}
+	}()
+	f.err = fn(ctx)
+	return f.err
 }
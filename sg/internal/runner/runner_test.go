@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunOnce(t *testing.T) {
+	t.Run("caches result", func(t *testing.T) {
+		var calls int
+		fn := func(context.Context) error {
+			calls++
+			return errors.New("boom")
+		}
+		for range 2 {
+			if err := RunOnce(context.Background(), t.Name(), fn); err == nil {
+				t.Fatal("expected error")
+			}
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("does not cache cancelled result", func(t *testing.T) {
+		var calls int
+		fn := func(ctx context.Context) error {
+			calls++
+			return ctx.Err()
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := RunOnce(ctx, t.Name(), fn); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if err := RunOnce(context.Background(), t.Name(), fn); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if calls != 2 {
+			t.Fatalf("expected 2 calls, got %d", calls)
+		}
+	})
+}
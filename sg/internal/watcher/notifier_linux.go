@@ -0,0 +1,61 @@
+package watcher
+
+import (
+	"os"
+	"syscall"
+)
+
+// inotifyNotifier signals changes using inotify.
+type inotifyNotifier struct {
+	fd   int
+	file *os.File
+	ch   chan struct{}
+}
+
+func newNotifier() (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// A non-blocking file is managed by the runtime poller, so that close interrupts reads.
+	// The descriptor is kept separately, since calling Fd on the file would make it blocking.
+	n := &inotifyNotifier{fd: fd, file: os.NewFile(uintptr(fd), "inotify"), ch: make(chan struct{}, 1)}
+	go n.read()
+	return n, nil
+}
+
+func (n *inotifyNotifier) read() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		if _, err := n.file.Read(buf); err != nil {
+			return
+		}
+		// The events are only used to wake up the watcher, which compares file states to find changes.
+		select {
+		case n.ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *inotifyNotifier) events() <-chan struct{} {
+	return n.ch
+}
+
+func (n *inotifyNotifier) watch(dirs []string) error {
+	const mask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE |
+		syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+	for _, dir := range dirs {
+		if _, err := syscall.InotifyAddWatch(n.fd, dir, mask); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *inotifyNotifier) close() error {
+	return n.file.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package watcher
+
+import "errors"
+
+func newNotifier() (notifier, error) {
+	return nil, errors.New("file system events are not supported on this platform")
+}
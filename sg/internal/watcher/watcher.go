@@ -0,0 +1,209 @@
+// Package watcher provides waiting for changes to the files in a git working tree.
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// debounce is how long the working tree has to be quiet before changes are reported.
+	debounce = 200 * time.Millisecond
+	// pollInterval is how often files are checked for changes when file system events are not available.
+	pollInterval = time.Second
+)
+
+// Watcher waits for changes to the files in a git working tree that are not ignored by git.
+type Watcher struct {
+	root     string
+	files    map[string]fileState
+	notifier notifier
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	// sum is the content hash of the file, computed lazily when the file is created or modified.
+	sum []byte
+}
+
+// notifier signals possible changes to files in a set of directories.
+type notifier interface {
+	// events returns a channel that receives a value when files in one of the watched directories change.
+	events() <-chan struct{}
+	// watch starts watching the provided directories.
+	watch(dirs []string) error
+	close() error
+}
+
+// New creates a Watcher for the git working tree at root, with the current state of the files as baseline.
+func New(root string) (*Watcher, error) {
+	w := &Watcher{root: root}
+	files, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.files = files
+	if n, err := newNotifier(); err == nil {
+		if err := n.watch(w.dirs()); err == nil {
+			w.notifier = n
+		} else {
+			_ = n.close()
+		}
+	}
+	return w, nil
+}
+
+// Polling reports whether the Watcher polls for changes, because file system events are not available.
+func (w *Watcher) Polling() bool {
+	return w.notifier == nil
+}
+
+// Close releases the resources of the Watcher.
+func (w *Watcher) Close() error {
+	if w.notifier == nil {
+		return nil
+	}
+	return w.notifier.close()
+}
+
+// Wait blocks until files have changed compared to the previous call to Wait, or to the creation of the Watcher,
+// and returns the changed files relative to the root.
+// Files with a new modification time but the same content are not considered changed.
+func (w *Watcher) Wait(ctx context.Context) ([]string, error) {
+	for {
+		if err := w.waitQuiet(ctx); err != nil {
+			return nil, err
+		}
+		files, err := w.snapshot()
+		if err != nil {
+			return nil, err
+		}
+		changed := w.diff(files)
+		w.files = files
+		if len(changed) == 0 {
+			continue
+		}
+		if w.notifier != nil {
+			// Pick up directories created since the last snapshot.
+			_ = w.notifier.watch(w.dirs())
+		}
+		return changed, nil
+	}
+}
+
+// waitQuiet waits for a file system event, or for the poll interval, followed by the debounce period without events.
+func (w *Watcher) waitQuiet(ctx context.Context) error {
+	if w.notifier == nil {
+		select {
+		case <-time.After(pollInterval):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case <-w.notifier.events():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	timer := time.NewTimer(debounce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.notifier.events():
+			timer.Reset(debounce)
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// snapshot returns the state of the files in the working tree that are not ignored by git.
+func (w *Watcher) snapshot() (map[string]fileState, error) {
+	var out bytes.Buffer
+	cmd := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = w.root
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	files := map[string]fileState{}
+	for name := range bytes.SplitSeq(out.Bytes(), []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(w.root, string(name)))
+		if err != nil {
+			continue // deleted from the working tree but not from the index
+		}
+		files[string(name)] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return files, nil
+}
+
+// diff returns the files that differ between the current state and next, and carries over content hashes to next.
+func (w *Watcher) diff(next map[string]fileState) []string {
+	var changed []string
+	for name, state := range next {
+		prev, ok := w.files[name]
+		switch {
+		case !ok:
+			state.sum = w.hash(name)
+			changed = append(changed, name)
+		case prev.modTime.Equal(state.modTime) && prev.size == state.size:
+			state.sum = prev.sum
+		default:
+			state.sum = w.hash(name)
+			if prev.sum == nil || !bytes.Equal(prev.sum, state.sum) {
+				changed = append(changed, name)
+			}
+		}
+		next[name] = state
+	}
+	for name := range w.files {
+		if _, ok := next[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func (w *Watcher) hash(name string) []byte {
+	f, err := os.Open(filepath.Join(w.root, name))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil
+	}
+	return h.Sum(nil)
+}
+
+// dirs returns the directories containing the files of the current state.
+func (w *Watcher) dirs() []string {
+	seen := map[string]bool{w.root: true}
+	result := []string{w.root}
+	for name := range w.files {
+		dir := filepath.Join(w.root, filepath.Dir(name))
+		if !seen[dir] {
+			seen[dir] = true
+			result = append(result, dir)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	git("init", "-q")
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "build/\n")
+	write("main.go", "package main")
+	w, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	// Ignored files are not reported, while new and modified files are.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		write("build/out.txt", "ignored")
+		write("api/api.proto", "syntax")
+	}()
+	changed, err := w.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changed, []string{"api/api.proto"}) {
+		t.Errorf("expected [api/api.proto], got %v", changed)
+	}
+
+	// Rewriting a known file with the same content is not a change.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		write("api/api.proto", "syntax")
+		time.Sleep(50 * time.Millisecond)
+		write("main.go", "package main\n")
+	}()
+	changed, err = w.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changed, []string{"main.go"}) {
+		t.Errorf("expected [main.go], got %v", changed)
+	}
+}
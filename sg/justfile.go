@@ -0,0 +1,112 @@
+package sg
+
+import (
+	"fmt"
+	"go/doc"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+// generateJustfile generates a justfile with the same targets as the Makefile mk.
+// The justfile of the root Makefile includes the justfiles of the namespace Makefiles as modules.
+func generateJustfile(g *codegen.File, pkg *sagePackage, mk Makefile, mks ...Makefile) error {
+	includePath, err := filepath.Rel(filepath.Dir(mk.Justfile), FromSageDir())
+	if err != nil {
+		return err
+	}
+	g.P("# To learn more, see ", includePath, "/main.go and https://github.com/einride/sage.")
+	g.P()
+	g.P(`sage_dir := source_directory() / `, justString(includePath))
+	g.P(`sagefile := sage_dir / `, justString(filepath.Join(binDir, sageFileBinary)))
+	g.P(`export GOWORK := env("GOWORK", "off")`)
+	vars := namespaceVars(reflect.ValueOf(mk.Namespace))
+	for _, v := range vars {
+		g.P(v.name, " := env(", justString(v.name), ", ", justString(formatNamespaceValue(v.value)), ")")
+	}
+	var recipes []string
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if function.Recv != mk.namespaceName() || isHiddenTarget(function) {
+			return
+		}
+		var recipe strings.Builder
+		if synopsis := pkg.Synopsis(function.Doc); synopsis != "" {
+			_, _ = fmt.Fprintf(&recipe, "# %s\n", synopsis)
+		}
+		if group := getTargetGroup(function); group != "" {
+			_, _ = fmt.Fprintf(&recipe, "[group(%s)]\n", justString(group))
+		}
+		args := toMakeVars(function.Decl.Type.Params.List[1:])
+		defaults := getTargetDefaults(function)
+		_, _ = recipe.WriteString(effectiveMakeTarget(function))
+		for _, arg := range args {
+			if value, ok := defaults[arg]; ok {
+				_, _ = fmt.Fprintf(&recipe, " %s=%s", arg, justString(value))
+			} else {
+				_, _ = fmt.Fprintf(&recipe, " %s", arg)
+			}
+		}
+		_, _ = fmt.Fprintf(&recipe, ": _sagefile\n\t@{{quote(sagefile)}} %s", getTargetFunctionName(function))
+		for _, v := range vars {
+			_, _ = fmt.Fprintf(&recipe, ` {{quote("%s=" + %s)}}`, v.name, v.name)
+		}
+		for _, arg := range args {
+			_, _ = fmt.Fprintf(&recipe, " {{quote(%s)}}", arg)
+		}
+		// The first recipe in a justfile is the default recipe.
+		if function.Name == mk.defaultTargetName() {
+			recipes = append([]string{recipe.String()}, recipes...)
+		} else {
+			recipes = append(recipes, recipe.String())
+		}
+	})
+	for _, recipe := range recipes {
+		g.P()
+		g.P(recipe)
+	}
+	g.P()
+	// The sagefile is rebuilt when the hash of the files it is built from differs from its stamp.
+	sageStamp := filepath.Join(binDir, sageFileStamp)
+	g.P("_sagefile:")
+	g.P(
+		"\t@cd {{quote(sage_dir)}} && if [ ! -x {{quote(sagefile)}} ] || ",
+		`[ "$(`, sageFileHashCommand, `)" != "$(cat `, sageStamp, ` 2>/dev/null)" ]; then `,
+		"go run . && ", sageFileHashCommand, " > ", sageStamp, "; fi",
+	)
+	g.P()
+	g.P("update-sage:")
+	g.P(
+		"\t@cd {{quote(sage_dir)}} && go get go.einride.tech/sage@latest && go mod tidy && go run . && ",
+		sageFileHashCommand, " > ", sageStamp,
+	)
+	g.P()
+	g.P("sage-check:")
+	g.P("\t@cd {{quote(sage_dir)}} && SAGE_CHECK=true go run .")
+	g.P()
+	g.P("clean-sage:")
+	g.P("\t@cd {{quote(sage_dir)}} && git clean -fdx ", toolsDir, " ", binDir, " ", buildDir)
+	if mk.namespaceName() == "" {
+		for _, i := range mks {
+			if i.namespaceName() == "" || i.Justfile == "" {
+				continue
+			}
+			modPath, err := filepath.Rel(filepath.Dir(mk.Justfile), i.Justfile)
+			if err != nil {
+				return err
+			}
+			g.P()
+			g.P("mod ", toMakeTarget(i.namespaceName()), " ", justString(modPath))
+		}
+	}
+	return nil
+}
+
+// justString returns s as a just string literal.
+func justString(s string) string {
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(s) + `"`
+}
@@ -0,0 +1,52 @@
+package sg
+
+import (
+	"strings"
+	"testing"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+func TestGenerateJustfile(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, helpTestSagefile)
+	mks := []Makefile{
+		{Justfile: FromGitRoot("justfile")},
+		{Justfile: FromGitRoot("proto", "justfile"), Namespace: Proto{}},
+	}
+	g := codegen.NewMakefile(codegen.FileConfig{})
+	if err := generateJustfile(g, pkg, mks[0], mks...); err != nil {
+		t.Fatal(err)
+	}
+	got := string(g.RawContent())
+	for _, expected := range []string{
+		"sage_dir := source_directory() / '.sage'\n",
+		"# Deploy deploys the service to env.\ndeploy service env='dev': _sagefile\n" +
+			"\t@{{quote(sagefile)}} Deploy {{quote(service)}} {{quote(env)}}\n",
+		"# Build builds the service.\n[group('build')]\nbuild: _sagefile\n",
+		"mod proto 'proto/justfile'\n",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected justfile to contain\n%s\nbut got\n%s", expected, got)
+		}
+	}
+	if strings.Contains(got, "helper") {
+		t.Errorf("expected justfile to not contain hidden target helper, got\n%s", got)
+	}
+}
+
+func TestJustString(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		value    string
+		expected string
+	}{
+		{value: "dev", expected: "'dev'"},
+		{value: `a"b\c`, expected: `'a"b\c'`},
+		{value: `it's "ok"`, expected: `"it's \"ok\""`},
+	} {
+		if got := justString(tt.value); got != tt.expected {
+			t.Errorf("justString(%q) = %s, want %s", tt.value, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package sg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sagePackage is a parsed package of sagefiles, or an imported package of namespaces.
+type sagePackage struct {
+	*doc.Package
+	// files are the parsed files of the package, and of the packages of imported namespaces.
+	files []*ast.File
+	// types is the type-checked package.
+	types *types.Package
+	// info has the types of the expressions in files, and the objects of their identifiers.
+	info *types.Info
+	// functions are the package-level functions of files, including the unexported functions that are removed from
+	// files by go/doc, by their objects in info.
+	functions map[types.Object]packageFunction
+	// importErrors are the errors of the imports of files that could not be resolved, by import path.
+	importErrors map[string]error
+	// skipped are the reasons that functions of imported namespaces are not targets.
+	skipped []string
+}
+
+// packageFunction is a package-level function and the file that declares it.
+type packageFunction struct {
+	decl *ast.FuncDecl
+	file *ast.File
+}
+
+// newSagePackage returns the package of the parsed files. The imports of the files are resolved in dir, see typeCheck.
+func newSagePackage(
+	fileSet *token.FileSet, pkgName string, files []*ast.File, importPath, dir string,
+) (*sagePackage, error) {
+	typesPkg, info, importErrors := typeCheck(fileSet, pkgName, files, dir)
+	functions := map[types.Object]packageFunction{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Recv == nil && info.Defs[funcDecl.Name] != nil {
+				functions[info.Defs[funcDecl.Name]] = packageFunction{decl: funcDecl, file: file}
+			}
+		}
+	}
+	pkg, err := doc.NewFromFiles(fileSet, files, importPath, doc.PreserveAST)
+	if err != nil {
+		return nil, err
+	}
+	return &sagePackage{
+		Package:      pkg,
+		files:        files,
+		types:        typesPkg,
+		info:         info,
+		functions:    functions,
+		importErrors: importErrors,
+	}, nil
+}
+
+// typeCheck type-checks files, and returns the package, the type information of files and the errors of the imports
+// that could not be resolved, by import path.
+//
+// The imports are resolved by the go command in dir, like the imports of the sagefile when it is built, for the
+// platform of sageBuildContext. The packages are imported from the export data of their builds, so that types from
+// any package are known without type-checking its dependencies from source. Expressions with types from imports that
+// could not be resolved are invalid.
+func typeCheck(
+	fileSet *token.FileSet, pkgName string, files []*ast.File, dir string,
+) (*types.Package, *types.Info, map[string]error) {
+	exports, importErrors := listExports(dir, importPaths(files))
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	config := types.Config{
+		Importer: importer.ForCompiler(fileSet, "gc", func(path string) (io.ReadCloser, error) {
+			if err, ok := importErrors[path]; ok {
+				return nil, err
+			}
+			export, ok := exports[path]
+			if !ok {
+				return nil, fmt.Errorf("no export data for %s", path)
+			}
+			return os.Open(export)
+		}),
+		// Errors in the sagefiles are reported when the sagefile is built, and import errors by importErrors.
+		Error: func(error) {},
+	}
+	pkg, _ := config.Check(pkgName, fileSet, files, info)
+	return pkg, info, importErrors
+}
+
+// importPaths returns the paths of the packages imported by files.
+func importPaths(files []*ast.File) []string {
+	var result []string
+	for _, file := range files {
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil || path == "C" || path == "unsafe" {
+				continue
+			}
+			result = append(result, path)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// listExports builds the packages with the provided import paths and their dependencies with the go command in dir,
+// and returns the export data files of the packages and the errors of the packages that could not be built, by import
+// path.
+func listExports(dir string, importPaths []string) (map[string]string, map[string]error) {
+	exports := map[string]string{}
+	importErrors := map[string]error{}
+	if len(importPaths) == 0 {
+		return exports, importErrors
+	}
+	failAll := func(err error) (map[string]string, map[string]error) {
+		for _, path := range importPaths {
+			importErrors[path] = err
+		}
+		return exports, importErrors
+	}
+	buildContext := sageBuildContext()
+	var stdout, stderr bytes.Buffer
+	args := []string{"list", "-e", "-export", "-deps", "-json=ImportPath,Export,Error"}
+	cmd := exec.Command("go", append(args, importPaths...)...)
+	cmd.Dir = dir
+	cmd.Env = append(
+		os.Environ(),
+		"GOOS="+buildContext.GOOS,
+		"GOARCH="+buildContext.GOARCH,
+		"CGO_ENABLED=0",
+		// The modules of the sagefile have been downloaded to build it, so imports that are not found in them are
+		// not looked up.
+		"GOPROXY=off",
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return failAll(fmt.Errorf("go list: %w: %s", err, strings.TrimSpace(stderr.String())))
+	}
+	decoder := json.NewDecoder(&stdout)
+	for decoder.More() {
+		var listed struct {
+			ImportPath string
+			Export     string
+			Error      *struct{ Err string }
+		}
+		if err := decoder.Decode(&listed); err != nil {
+			return failAll(fmt.Errorf("go list: %w", err))
+		}
+		switch {
+		case listed.Error != nil:
+			importErrors[listed.ImportPath] = errors.New(listed.Error.Err)
+		case listed.Export != "":
+			exports[listed.ImportPath] = listed.Export
+		}
+	}
+	return exports, importErrors
+}
+
+// typeOf returns the type of expr, or nil if it is not known.
+func (p *sagePackage) typeOf(expr ast.Expr) types.Type {
+	if p == nil || p.info == nil {
+		return nil
+	}
+	t := p.info.TypeOf(expr)
+	if t == nil || t == types.Typ[types.Invalid] {
+		return nil
+	}
+	return t
+}
+
+// importError returns the error of the import of a package that expr refers to, if the import could not be resolved.
+func (p *sagePackage) importError(expr ast.Expr) error {
+	var result error
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok && result == nil {
+			if ident, ok := selector.X.(*ast.Ident); ok {
+				if pkgName, ok := p.info.Uses[ident].(*types.PkgName); ok {
+					result = p.importErrors[pkgName.Imported().Path()]
+				}
+			}
+		}
+		return result == nil
+	})
+	return result
+}
+
+// declares returns true if the type of expr is a named type declared in the package.
+func (p *sagePackage) declares(expr ast.Expr) bool {
+	named, ok := types.Unalias(p.typeOf(expr)).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg() == p.types
+}
+
+// loadSagePackage loads the package of the sagefiles in dir.
+//
+// Only the files that are part of a build of the package are loaded, so test files and files excluded by build
+// constraints may declare other packages.
+func loadSagePackage(dir string) (*sagePackage, error) {
+	buildContext := sageBuildContext()
+	buildPkg, err := buildContext.ImportDir(dir, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sagefiles: %w", err)
+	}
+	return parsePackage(buildPkg, "./", dir)
+}
+
+// sageBuildContext returns the build context that build constraints of sagefiles are evaluated in.
+//
+// The context is fixed to linux/amd64 without cgo, rather than the platform that the files are generated on, so that
+// the generated Makefiles do not differ between platforms. Targets should not be declared in files that are excluded
+// on other platforms, since the sagefile would not compile on those platforms.
+func sageBuildContext() build.Context {
+	result := build.Default
+	result.GOOS = "linux"
+	result.GOARCH = "amd64"
+	result.CgoEnabled = false
+	return result
+}
+
+// loadImportedNamespaces adds the namespaces of mks that are declared in other packages than the sagefiles to pkg,
+// so that their methods become targets.
+//
+// The packages are located from the sage directory dir, like the imports of the sagefiles. Methods with parameters
+// of types declared in the imported package are not targets.
+func loadImportedNamespaces(pkg *sagePackage, dir string, mks []Makefile) error {
+	loaded := map[reflect.Type]bool{}
+	for _, mk := range mks {
+		if mk.Namespace == nil {
+			continue
+		}
+		t := reflect.TypeOf(mk.Namespace)
+		if t.PkgPath() == "" || t.PkgPath() == "main" || loaded[t] {
+			continue
+		}
+		loaded[t] = true
+		if err := loadImportedNamespace(pkg, dir, t.PkgPath(), t.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadImportedNamespace adds the namespace type name declared in the package importPath to pkg.
+func loadImportedNamespace(pkg *sagePackage, dir, importPath, name string) error {
+	if findType(pkg, name) != nil {
+		return fmt.Errorf("namespace %s.%s conflicts with the type %s declared in the sagefiles", importPath, name, name)
+	}
+	buildContext := sageBuildContext()
+	buildContext.Dir = dir
+	buildPkg, err := buildContext.Import(importPath, dir, 0)
+	if err != nil {
+		return fmt.Errorf("failed to load namespace %s.%s: %w", importPath, name, err)
+	}
+	importedPkg, err := parsePackage(buildPkg, buildPkg.ImportPath, dir)
+	if err != nil {
+		return fmt.Errorf("failed to load namespace %s.%s: %w", importPath, name, err)
+	}
+	namespace := findType(importedPkg, name)
+	if namespace == nil || !isNamespace(namespace) {
+		return fmt.Errorf("%s.%s is not a namespace", importPath, name)
+	}
+	imported := *namespace
+	imported.Methods = nil
+	for _, method := range namespace.Methods {
+		if !isTargetFunctionCandidate(importedPkg, method) {
+			continue
+		}
+		if reason := importedTargetSkipReason(importedPkg, method); reason != "" {
+			pkg.skipped = append(pkg.skipped, fmt.Sprintf("%s.%s.%s: %s", importPath, name, method.Name, reason))
+			continue
+		}
+		imported.Methods = append(imported.Methods, method)
+	}
+	pkg.Types = append(pkg.Types, &imported)
+	pkg.files = append(pkg.files, importedPkg.files...)
+	maps.Copy(pkg.info.Types, importedPkg.info.Types)
+	maps.Copy(pkg.info.Defs, importedPkg.info.Defs)
+	maps.Copy(pkg.info.Uses, importedPkg.info.Uses)
+	maps.Copy(pkg.functions, importedPkg.functions)
+	maps.Copy(pkg.importErrors, importedPkg.importErrors)
+	return nil
+}
+
+// importedTargetSkipReason returns the reason that a target function candidate of an imported package is not a target
+// that can be called from the sagefiles, or an empty string if it is. Parameters of types declared in the imported package
+// are not supported, since the generated sagefile entrypoint refers to those types by name in the package of the
+// sagefiles.
+func importedTargetSkipReason(importedPkg *sagePackage, function *doc.Func) string {
+	params := function.Decl.Type.Params.List
+	if param := unsupportedCustomParam(importedPkg, params[1:]); param != nil {
+		return unsupportedParamReason(importedPkg, param)
+	}
+	for _, param := range params[1:] {
+		if importedPkg.declares(param.Type) {
+			return "parameter type " + types.ExprString(param.Type) + " is declared in the imported package"
+		}
+	}
+	return ""
+}
+
+// parsePackage parses the files of the package buildPkg that are part of a build of the package, and resolves their
+// imports in dir.
+func parsePackage(buildPkg *build.Package, importPath, dir string) (*sagePackage, error) {
+	fileSet := token.NewFileSet()
+	files := make([]*ast.File, 0, len(buildPkg.GoFiles))
+	for _, filename := range buildPkg.GoFiles {
+		file, err := parser.ParseFile(fileSet, filepath.Join(buildPkg.Dir, filename), nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", buildPkg.ImportPath, err)
+		}
+		files = append(files, file)
+	}
+	return newSagePackage(fileSet, buildPkg.Name, files, importPath, dir)
+}
+
+// isContextType returns true if t is context.Context, including through a type alias.
+func isContextType(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
@@ -0,0 +1,254 @@
+package sg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadSagePackage(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "main.go"), `package main
+
+import (
+	"context"
+
+	"go.einride.tech/sage/sg"
+)
+
+func main() {
+	sg.GenerateMakefiles(sg.Makefile{Path: sg.FromGitRoot("Makefile")})
+}
+
+func Build(ctx context.Context) error { return nil }
+
+func NotATarget(s string) error { return nil }
+`)
+	writeTestFile(t, filepath.Join(dir, "context.go"), `package main
+
+import (
+	stdcontext "context"
+	. "context"
+)
+
+type Ctx = stdcontext.Context
+
+func Renamed(ctx stdcontext.Context) error { return nil }
+
+func DotImport(ctx Context) error { return nil }
+
+func Alias(ctx Ctx) error { return nil }
+`)
+	writeTestFile(t, filepath.Join(dir, "linux.go"), `//go:build linux && amd64 && !cgo
+
+package main
+
+import (
+	"context"
+	tm "time"
+)
+
+func Wait(ctx context.Context, timeout tm.Duration) error { return nil }
+`)
+	writeTestFile(t, filepath.Join(dir, "darwin.go"), `//go:build darwin
+
+package main
+
+import "context"
+
+func Darwin(ctx context.Context) error { return nil }
+`)
+	writeTestFile(t, filepath.Join(dir, "tools.go"), `//go:build tools
+
+package tools
+
+import "context"
+
+func Tool(ctx context.Context) error { return nil }
+`)
+	writeTestFile(t, filepath.Join(dir, "main_test.go"), `package main_test
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {}
+
+func Test(ctx context.Context) error { return nil }
+`)
+	pkg, err := loadSagePackage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var targets []string
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		targets = append(targets, function.Name)
+	})
+	slices.Sort(targets)
+	// Build constraints are evaluated for linux/amd64 without cgo, whatever the platform.
+	expected := []string{"Alias", "Build", "DotImport", "Renamed", "Wait"}
+	if !slices.Equal(targets, expected) {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+	for _, function := range pkg.Funcs {
+		if function.Name == "Renamed" {
+			// The parameter types are checked with the type information, without rewriting the syntax tree.
+			if got := types.ExprString(function.Decl.Type.Params.List[0].Type); got != "stdcontext.Context" {
+				t.Errorf("expected the context parameter of Renamed to be unchanged, got %s", got)
+			}
+		}
+	}
+}
+
+func TestLoadSagePackage_ModuleImports(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "go.mod"), "module example.com/org\n\ngo 1.25\n")
+	writeTestFile(t, filepath.Join(root, "kinds", "kinds.go"), `package kinds
+
+type Level int
+
+func (l *Level) UnmarshalText(text []byte) error { return nil }
+`)
+	sageDir := filepath.Join(root, ".sage")
+	writeTestFile(t, filepath.Join(sageDir, "go.mod"), "module example.com/sage\n\ngo 1.25\n\n"+
+		"require example.com/org v0.0.0\n\nreplace example.com/org => ../\n")
+	writeTestFile(t, filepath.Join(sageDir, "main.go"), `package main
+
+import (
+	"context"
+
+	"example.com/missing/values"
+	"example.com/org/kinds"
+)
+
+func SetLevel(ctx context.Context, level kinds.Level) error { return nil }
+
+func Missing(ctx context.Context, value values.Value) error { return nil }
+`)
+	pkg, err := loadSagePackage(sageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var targets []string
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		targets = append(targets, function.Name)
+		if kind := customParamKind(pkg, function.Decl.Type.Params.List[1].Type); kind != textUnmarshalerParam {
+			t.Errorf("expected the parameter of %s to be a text unmarshaler, got %v", function.Name, kind)
+		}
+	})
+	// Types from other modules are resolved like when the sagefile is built.
+	if expected := []string{"SetLevel"}; !slices.Equal(targets, expected) {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+	// Types from imports that can not be resolved are reported.
+	skipped := skippedTargets(pkg)
+	if len(skipped) != 1 || !strings.HasPrefix(skipped[0], "Missing: unresolved parameter type values.Value: ") {
+		t.Errorf("expected Missing to be skipped with an unresolved parameter type, got %q", skipped)
+	}
+}
+
+func TestLoadImportedNamespace(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "go.mod"), "module example.com/org\n\ngo 1.25\n")
+	writeTestFile(t, filepath.Join(root, "targets", "go.go"), `package targets
+
+import (
+	"context"
+	"time"
+
+	"go.einride.tech/sage/sg"
+)
+
+type Go sg.Namespace
+
+type Mode string
+
+// Test runs the Go tests.
+func (Go) Test(ctx context.Context, timeout time.Duration) error { return nil }
+
+// Lint lints the Go files.
+func (Go) Lint(ctx context.Context) error { return nil }
+
+func (Go) Build(ctx context.Context, mode Mode) error { return nil }
+
+func (Go) helper(ctx context.Context) error { return nil }
+`)
+	sageDir := filepath.Join(root, ".sage")
+	writeTestFile(t, filepath.Join(sageDir, "main.go"), `package main
+
+import (
+	"context"
+
+	"example.com/org/targets"
+	"go.einride.tech/sage/sg"
+)
+
+func main() {
+	sg.GenerateMakefiles(
+		sg.Makefile{Path: sg.FromGitRoot("Makefile")},
+		sg.Makefile{Namespace: targets.Go{}, Path: sg.FromGitRoot("go/Makefile")},
+	)
+}
+
+type Mode string
+
+func Default(ctx context.Context) error { return nil }
+`)
+	pkg, err := loadSagePackage(sageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadImportedNamespace(pkg, sageDir, "example.com/org/targets", "Go"); err != nil {
+		t.Fatal(err)
+	}
+	var targets []string
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		targets = append(targets, getTargetFunctionName(function))
+	})
+	slices.Sort(targets)
+	// Build is not a target, since the type of its parameter is declared in the imported package.
+	expected := []string{"Default", "Go:Lint", "Go:Test"}
+	if !slices.Equal(targets, expected) {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+	if err := loadImportedNamespace(pkg, sageDir, "example.com/org/targets", "Mode"); err == nil {
+		t.Error("expected error for a type that conflicts with a type in the sagefiles")
+	}
+	if err := loadImportedNamespace(pkg, sageDir, "example.com/org/missing", "Go"); err == nil {
+		t.Error("expected error for a package that does not exist")
+	}
+}
+
+// parseTestSagePackage returns the package of sagefiles with the provided sources.
+func parseTestSagePackage(t *testing.T, srcs ...string) *sagePackage {
+	t.Helper()
+	fileSet := token.NewFileSet()
+	files := make([]*ast.File, 0, len(srcs))
+	for i, src := range srcs {
+		filename := "main.go"
+		if i > 0 {
+			filename = fmt.Sprintf("file%d.go", i)
+		}
+		file, err := parser.ParseFile(fileSet, filename, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	pkg, err := newSagePackage(fileSet, files[0].Name.Name, files, "./", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
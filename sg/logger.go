@@ -3,7 +3,9 @@ package sg
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -13,19 +15,34 @@ import (
 type loggerContextKey struct{}
 
 // NewLogger returns a standard logger.
+//
+// When the environment variable SAGE_LOG_FORMAT=json is set, the logger writes JSON lines, see SlogLogger.
 func NewLogger(name string) *log.Logger {
+	if isJSONLogFormat() {
+		return slog.NewLogLogger(newLogHandler(os.Stderr, name, nil), slog.LevelInfo)
+	}
+	return newLogger(os.Stderr, name)
+}
+
+func newLogger(w io.Writer, name string) *log.Logger {
+	return log.New(w, fmt.Sprintf("[%s] ", loggerPrefix(name)), 0)
+}
+
+// loggerPrefix returns the display name of a target in log output.
+func loggerPrefix(name string) string {
 	prefix := name
 	prefix = strings.TrimPrefix(prefix, "main.")
-	prefix = strings.TrimPrefix(prefix, "go.einride.tech/sage/tools/")
+	// Targets of imported packages, such as tools and imported namespaces, are prefixed with their package name.
+	if i := strings.LastIndex(prefix, "/"); i != -1 {
+		prefix = prefix[i+1:]
+	}
 
 	// Separate namespace and target with colon, expecting the string to be
 	// of the format `namespace.target`.
 	if len(strings.Split(prefix, ".")) > 1 {
 		prefix = strings.Join(strings.Split(prefix, "."), ":")
 	}
-	prefix = strcase.ToKebab(prefix)
-	prefix = fmt.Sprintf("[%s] ", prefix)
-	return log.New(os.Stderr, prefix, 0)
+	return strcase.ToKebab(prefix)
 }
 
 // WithLogger attaches a log.Logger to the provided context.
@@ -36,7 +53,33 @@ func WithLogger(ctx context.Context, logger *log.Logger) context.Context {
 // AppendLoggerPrefix appends a prefix to the current logger.
 func AppendLoggerPrefix(ctx context.Context, prefix string) context.Context {
 	logger := Logger(ctx)
-	return WithLogger(ctx, log.New(logger.Writer(), logger.Prefix()+prefix, logger.Flags()))
+	ctx = WithLogger(ctx, log.New(logger.Writer(), logger.Prefix()+prefix, logger.Flags()))
+	if ctx.Value(slogLoggerContextKey{}) == nil {
+		return ctx
+	}
+	if handler, ok := SlogLogger(ctx).Handler().(*textLogHandler); ok {
+		handler = &textLogHandler{logger: Logger(ctx), attrs: handler.attrs, group: handler.group}
+		return WithSlogLogger(ctx, slog.New(handler))
+	}
+	// Nested prefixes are accumulated in a single attribute, on the logger the first prefix was appended to.
+	state := slogPrefix{base: SlogLogger(ctx), prefix: strings.TrimSpace(prefix)}
+	if parent, ok := ctx.Value(slogPrefixContextKey{}).(slogPrefix); ok && parent.logger == state.base {
+		state.base = parent.base
+		state.prefix = parent.prefix + " " + state.prefix
+	}
+	state.logger = state.base.With(prefixLogKey, state.prefix)
+	return WithSlogLogger(context.WithValue(ctx, slogPrefixContextKey{}, state), state.logger)
+}
+
+type slogPrefixContextKey struct{}
+
+// slogPrefix is the state of a slog.Logger with a prefix appended by AppendLoggerPrefix.
+type slogPrefix struct {
+	// base is the logger without the prefix.
+	base *slog.Logger
+	// logger is the logger with the prefix.
+	logger *slog.Logger
+	prefix string
 }
 
 // Logger returns the log.Logger attached to ctx, or a default logger.
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"unicode"
 
@@ -25,6 +26,10 @@ type Makefile struct {
 	Namespace     any
 	Path          string
 	DefaultTarget any
+	// Justfile is an optional path of a justfile to generate with the same targets.
+	Justfile string
+	// Taskfile is an optional path of a Taskfile.yml to generate with the same targets.
+	Taskfile string
 }
 
 func (m Makefile) namespaceName() string {
@@ -40,6 +45,9 @@ func (m Makefile) defaultTargetName() string {
 	}
 	result := runtime.FuncForPC(reflect.ValueOf(m.DefaultTarget).Pointer()).Name()
 	result = strings.TrimPrefix(result, "main.")
+	if m.Namespace != nil {
+		result = strings.TrimPrefix(result, reflect.TypeOf(m.Namespace).PkgPath()+".")
+	}
 	result = strings.TrimPrefix(result, m.namespaceName()+".")
 	result = strings.Split(result, "-")[0]
 	for _, r := range result {
@@ -52,18 +60,38 @@ func (m Makefile) defaultTargetName() string {
 
 var validMakeTarget = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
 
-// getMakeTargetOverride parses the doc comment of a function for a "//sage:target" directive.
-// Returns the override target name, or empty string if no annotation is found.
+// targetDirective is a "//sage:<name> <value>" directive in the doc comment of a target function.
+type targetDirective struct {
+	name  string
+	value string
+}
+
+// targetDirectives returns the sage directives in the doc comment of a function, in order.
 // We read from function.Decl.Doc (the raw AST comment group) because Go's
 // ast.CommentGroup.Text() filters out directive-style comments (//word:...).
-func getMakeTargetOverride(function *doc.Func) string {
+func targetDirectives(function *doc.Func) []targetDirective {
 	if function.Decl == nil || function.Decl.Doc == nil {
-		return ""
+		return nil
 	}
+	var result []targetDirective
 	for _, comment := range function.Decl.Doc.List {
 		text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
-		if after, ok := strings.CutPrefix(text, "sage:target "); ok {
-			return strings.TrimSpace(after)
+		directive, ok := strings.CutPrefix(text, "sage:")
+		if !ok {
+			continue
+		}
+		name, value, _ := strings.Cut(directive, " ")
+		result = append(result, targetDirective{name: name, value: strings.TrimSpace(value)})
+	}
+	return result
+}
+
+// getMakeTargetOverride parses the doc comment of a function for a "//sage:target" directive.
+// Returns the override target name, or empty string if no annotation is found.
+func getMakeTargetOverride(function *doc.Func) string {
+	for _, directive := range targetDirectives(function) {
+		if directive.name == "target" {
+			return directive.value
 		}
 	}
 	return ""
@@ -91,9 +119,59 @@ func effectiveMakeTarget(function *doc.Func) string {
 	return toMakeTarget(getTargetFunctionName(function))
 }
 
-// findDocFunc finds a doc.Func by name in a doc.Package.
+// getTargetDefaults parses the doc comment of a function for "//sage:default name=value" directives.
+// Returns the default values keyed by Make variable. The name can be either the parameter name or its Make variable.
+// Panics if a directive is malformed or does not name a parameter of the function.
+func getTargetDefaults(function *doc.Func) map[string]string {
+	var makeVars []string
+	if function.Decl != nil && function.Decl.Type != nil && function.Decl.Type.Params != nil &&
+		len(function.Decl.Type.Params.List) > 0 {
+		makeVars = toMakeVars(function.Decl.Type.Params.List[1:])
+	}
+	var result map[string]string
+	for _, directive := range targetDirectives(function) {
+		if directive.name != "default" {
+			continue
+		}
+		after := directive.value
+		name, value, ok := strings.Cut(after, "=")
+		if !ok {
+			panic(fmt.Sprintf("sage:default annotation %q on %s must have the form name=value", after, function.Name))
+		}
+		makeVar := strcase.ToSnake(strings.TrimSpace(name))
+		if !slices.Contains(makeVars, makeVar) {
+			panic(fmt.Sprintf("sage:default annotation %q on %s does not name a parameter", after, function.Name))
+		}
+		if result == nil {
+			result = map[string]string{}
+		}
+		result[makeVar] = value
+	}
+	return result
+}
+
+// isHiddenTarget reports whether the doc comment of a function has a "//sage:hidden" directive.
+// Hidden targets are not generated as Make targets, but can still be run with Deps.
+func isHiddenTarget(function *doc.Func) bool {
+	return slices.ContainsFunc(targetDirectives(function), func(directive targetDirective) bool {
+		return directive.name == "hidden"
+	})
+}
+
+// getTargetGroup parses the doc comment of a function for a "//sage:group" directive.
+// Returns the group name, or empty string if no directive is found.
+func getTargetGroup(function *doc.Func) string {
+	for _, directive := range targetDirectives(function) {
+		if directive.name == "group" {
+			return directive.value
+		}
+	}
+	return ""
+}
+
+// findDocFunc finds a doc.Func by name in a sagePackage.
 // It searches both top-level functions and type methods.
-func findDocFunc(pkg *doc.Package, name string) *doc.Func {
+func findDocFunc(pkg *sagePackage, name string) *doc.Func {
 	for _, f := range pkg.Funcs {
 		if f.Name == name {
 			return f
@@ -109,7 +187,7 @@ func findDocFunc(pkg *doc.Package, name string) *doc.Func {
 	return nil
 }
 
-func generateMakefile(_ context.Context, g *codegen.File, pkg *doc.Package, mk Makefile, mks ...Makefile) error {
+func generateMakefile(_ context.Context, g *codegen.File, pkg *sagePackage, mk Makefile, mks ...Makefile) error {
 	includePath, err := filepath.Rel(filepath.Dir(mk.Path), FromSageDir())
 	if err != nil {
 		return err
@@ -119,14 +197,32 @@ func generateMakefile(_ context.Context, g *codegen.File, pkg *doc.Package, mk M
 		g.P()
 		defaultTarget := toMakeTarget(defaultName)
 		if f := findDocFunc(pkg, defaultName); f != nil {
+			if isHiddenTarget(f) {
+				panic(fmt.Sprintf("default target %s must not be hidden", defaultName))
+			}
 			defaultTarget = effectiveMakeTarget(f)
 		}
 		g.P(".DEFAULT_GOAL := ", defaultTarget)
 	}
+	vars := namespaceVars(reflect.ValueOf(mk.Namespace))
+	if len(vars) > 0 {
+		g.P()
+		g.P("# Fields of the ", mk.namespaceName(), " namespace, passed on the command line of the sagefile.")
+		for _, v := range vars {
+			g.P(v.name, " ?= ", strings.ReplaceAll(formatNamespaceValue(v.value), "$", "$$"))
+		}
+	}
 	g.P()
 	g.P("cwd := $(dir $(realpath $(firstword $(MAKEFILE_LIST))))")
 	g.P("sagefile := $(abspath $(cwd)/", filepath.Join(includePath, binDir, sageFileBinary), ")")
 	g.P()
+	g.P("# Rebuild the sagefile when the hash of the files it is built from differs from its stamp.")
+	g.P("sagehash = cd $(abspath $(cwd)/", includePath, ") && ", sageFileHashCommand)
+	g.P("sagestamp := $(abspath $(cwd)/", filepath.Join(includePath, binDir, sageFileStamp), ")")
+	g.P("ifneq ($(shell $(sagehash)),$(shell cat $(sagestamp) 2>/dev/null))")
+	g.P(".PHONY: $(sagefile)")
+	g.P("endif")
+	g.P()
 	g.P("# Setup Go.")
 	g.P("go := $(shell command -v go 2>/dev/null)")
 	g.P("export GOWORK ?= off")
@@ -152,17 +248,56 @@ func generateMakefile(_ context.Context, g *codegen.File, pkg *doc.Package, mk M
 	g.P("\t@chmod +x $(go)")
 	g.P("endif")
 	g.P()
-	g.P(".PHONY: $(sagefile)")
 	g.P("$(sagefile): $(go)")
-	g.P("\t@cd ", includePath, " && $(go) mod tidy && $(go) run .")
+	g.P("\t@cd ", includePath, " && $(go) run .")
+	g.P("\t@$(sagehash) > $(sagestamp)")
 	g.P()
 	g.P(".PHONY: sage")
-	g.P("sage:")
-	g.P("\t@$(MAKE) $(sagefile)")
+	g.P("sage: $(go)")
+	g.P("\t@cd ", includePath, " && $(go) mod tidy && $(go) run .")
+	g.P("\t@$(sagehash) > $(sagestamp)")
 	g.P()
 	g.P(".PHONY: update-sage")
 	g.P("update-sage: $(go)")
 	g.P("\t@cd ", includePath, " && $(go) get go.einride.tech/sage@latest && $(go) mod tidy && $(go) run .")
+	g.P("\t@$(sagehash) > $(sagestamp)")
+	g.P()
+	g.P(".PHONY: sage-check")
+	g.P("sage-check: $(go)")
+	g.P("\t@cd ", includePath, " && SAGE_CHECK=true $(go) run .")
+	g.P()
+	targets := helpTargets(pkg, mk)
+	hasTarget := func(name string) bool {
+		return slices.ContainsFunc(targets, func(t helpTarget) bool { return t.name == name })
+	}
+	// A target named help in the sagefiles takes precedence over the generated help.
+	if !hasTarget("help") {
+		g.P(".PHONY: help")
+		g.P("help: $(sagefile)")
+		if mk.namespaceName() == "" {
+			g.P("\t@$(sagefile) help")
+		} else {
+			g.P("\t@$(sagefile) help ", mk.namespaceName())
+		}
+		g.P()
+	}
+	g.P(".PHONY: sage-graph")
+	g.P("sage-graph: $(sagefile)")
+	g.P("\t@cat ", filepath.Join(includePath, buildDir, "graph"), ".$(or $(SAGE_GRAPH_FORMAT),dot)")
+	g.P()
+	// Likewise for watch. Targets named watch-<target> take precedence over the pattern rule, since Make prefers
+	// explicit rules.
+	if !hasTarget("watch") {
+		g.P(".PHONY: watch")
+		g.P("watch:")
+		g.P("ifndef TARGET")
+		g.P("\t $(error missing argument TARGET=\"...\")")
+		g.P("endif")
+		g.P("\t@$(MAKE) --no-print-directory $(TARGET) SAGE_WATCH=true")
+		g.P()
+	}
+	g.P("watch-%:")
+	g.P("\t@$(MAKE) --no-print-directory $* SAGE_WATCH=true")
 	g.P()
 	g.P(".PHONY: clean-sage")
 	g.P("clean-sage:")
@@ -176,7 +311,7 @@ func generateMakefile(_ context.Context, g *codegen.File, pkg *doc.Package, mk M
 	)
 	seenTargets := map[string]string{} // make target -> Go function name
 	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
-		if function.Recv == mk.namespaceName() {
+		if function.Recv == mk.namespaceName() && !isHiddenTarget(function) {
 			target := effectiveMakeTarget(function)
 			funcName := getTargetFunctionName(function)
 			if existing, ok := seenTargets[target]; ok {
@@ -188,25 +323,35 @@ func generateMakefile(_ context.Context, g *codegen.File, pkg *doc.Package, mk M
 			seenTargets[target] = funcName
 			g.P()
 			g.P(".PHONY: ", target)
-			g.P(target, ": $(sagefile)")
 			args := toMakeVars(function.Decl.Type.Params.List[1:])
+			defaults := getTargetDefaults(function)
+			for _, arg := range args {
+				if value, ok := defaults[arg]; ok {
+					g.P(target, ": ", arg, " ?= ", strings.ReplaceAll(value, "$", "$$"))
+				}
+			}
+			g.P(target, ": $(sagefile)")
 			if len(args) > 0 {
 				for _, arg := range args {
+					if _, ok := defaults[arg]; ok {
+						continue
+					}
 					g.P("ifndef ", arg)
 					g.P("\t $(error missing argument ", arg, `="...")`)
 					g.P("endif")
 				}
 			}
-			g.P(
-				"\t@$(sagefile) ",
-				toSageFunction(getTargetFunctionName(function), args),
-			)
+			call := getTargetFunctionName(function)
+			for _, v := range vars {
+				call += fmt.Sprintf(" \"%s=$(%s)\"", v.name, v.name)
+			}
+			g.P("\t@$(sagefile) ", toSageFunction(call, args))
 		}
 	})
 	// Add additional makefiles to default makefile
 	if mk.namespaceName() == "" {
 		for _, i := range mks {
-			if i.namespaceName() == "" {
+			if i.namespaceName() == "" || i.Path == "" {
 				continue
 			}
 			mkPath, err := filepath.Rel(FromGitRoot(), filepath.Dir(i.Path))
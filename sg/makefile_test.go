@@ -1,10 +1,16 @@
 package sg
 
 import (
+	"context"
 	"go/ast"
 	"go/doc"
 	"go/token"
+	"maps"
+	"slices"
+	"strings"
 	"testing"
+
+	"go.einride.tech/sage/internal/codegen"
 )
 
 // makeDocFunc creates a doc.Func with the given name and raw doc comment lines.
@@ -27,6 +33,29 @@ func makeDocFunc(name string, commentLines ...string) *doc.Func {
 	return f
 }
 
+func TestTargetDirectives(t *testing.T) {
+	t.Parallel()
+	function := makeDocFunc(
+		"Build",
+		"// Build builds the project.",
+		"//sage:group Build",
+		"//sage:hidden",
+		"// sage:default  mode=fast ",
+		"//nolint:gocritic",
+	)
+	expected := []targetDirective{
+		{name: "group", value: "Build"},
+		{name: "hidden"},
+		{name: "default", value: "mode=fast"},
+	}
+	if got := targetDirectives(function); !slices.Equal(got, expected) {
+		t.Errorf("targetDirectives() = %v, want %v", got, expected)
+	}
+	if got := targetDirectives(&doc.Func{Name: "Build"}); got != nil {
+		t.Errorf("targetDirectives() = %v, want nil", got)
+	}
+}
+
 func TestGetMakeTargetOverride(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -187,3 +216,222 @@ func TestToMakeTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestGetTargetDefaults(t *testing.T) {
+	t.Parallel()
+	withParams := func(f *doc.Func, names ...string) *doc.Func {
+		params := []*ast.Field{{Type: &ast.SelectorExpr{X: ast.NewIdent("context"), Sel: ast.NewIdent("Context")}}}
+		for _, name := range names {
+			params = append(params, &ast.Field{Names: []*ast.Ident{ast.NewIdent(name)}, Type: ast.NewIdent("string")})
+		}
+		f.Decl.Type = &ast.FuncType{Params: &ast.FieldList{List: params}}
+		return f
+	}
+	tests := []struct {
+		name      string
+		function  *doc.Func
+		expected  map[string]string
+		wantPanic bool
+	}{
+		{
+			name:     "no annotation",
+			function: withParams(makeDocFunc("Deploy", "// Deploy deploys."), "env"),
+		},
+		{
+			name:     "default",
+			function: withParams(makeDocFunc("Deploy", "//sage:default env=dev"), "env"),
+			expected: map[string]string{"env": "dev"},
+		},
+		{
+			name: "multiple defaults",
+			function: withParams(
+				makeDocFunc("Deploy", "//sage:default env=dev", "//sage:default imageTag=latest"),
+				"env",
+				"imageTag",
+			),
+			expected: map[string]string{"env": "dev", "image_tag": "latest"},
+		},
+		{
+			name:     "make variable name and empty value",
+			function: withParams(makeDocFunc("Deploy", "//sage:default image_tag="), "imageTag"),
+			expected: map[string]string{"image_tag": ""},
+		},
+		{
+			name:     "value with equals sign",
+			function: withParams(makeDocFunc("Deploy", "//sage:default flags=a=b"), "flags"),
+			expected: map[string]string{"flags": "a=b"},
+		},
+		{
+			name:      "unknown parameter",
+			function:  withParams(makeDocFunc("Deploy", "//sage:default region=eu"), "env"),
+			wantPanic: true,
+		},
+		{
+			name:      "missing value",
+			function:  withParams(makeDocFunc("Deploy", "//sage:default env"), "env"),
+			wantPanic: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var got map[string]string
+			didPanic := false
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						didPanic = true
+					}
+				}()
+				got = getTargetDefaults(tt.function)
+			}()
+			if didPanic != tt.wantPanic {
+				t.Fatalf("getTargetDefaults(): panicked = %v, want %v", didPanic, tt.wantPanic)
+			}
+			if !maps.Equal(got, tt.expected) {
+				t.Errorf("getTargetDefaults() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateMakefile_SagefileStamp(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, helpTestSagefile)
+	mk := Makefile{Path: FromGitRoot("Makefile")}
+	g := codegen.NewMakefile(codegen.FileConfig{})
+	if err := generateMakefile(context.Background(), g, pkg, mk, mk); err != nil {
+		t.Fatal(err)
+	}
+	got := string(g.RawContent())
+	for _, expected := range []string{
+		"sagestamp := $(abspath $(cwd)/.sage/bin/sagefile.stamp)\n",
+		"ifneq ($(shell $(sagehash)),$(shell cat $(sagestamp) 2>/dev/null))\n.PHONY: $(sagefile)\nendif\n",
+		"$(sagefile): $(go)\n\t@cd .sage && $(go) run .\n\t@$(sagehash) > $(sagestamp)\n",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected Makefile to contain\n%s\nbut got\n%s", expected, got)
+		}
+	}
+}
+
+func TestGenerateMakefile_WatchTarget(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		name      string
+		src       string
+		generated bool
+	}{
+		{
+			name:      "generated",
+			src:       "package main\n\nimport \"context\"\n\nfunc Build(ctx context.Context) error { return nil }\n",
+			generated: true,
+		},
+		{
+			name: "sagefile target",
+			src:  "package main\n\nimport \"context\"\n\nfunc Watch(ctx context.Context) error { return nil }\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pkg := parseTestSagePackage(t, tt.src)
+			mk := Makefile{Path: FromGitRoot("Makefile")}
+			g := codegen.NewMakefile(codegen.FileConfig{})
+			if err := generateMakefile(context.Background(), g, pkg, mk, mk); err != nil {
+				t.Fatal(err)
+			}
+			got := string(g.RawContent())
+			if n := strings.Count(got, "\nwatch:"); n != 1 {
+				t.Errorf("expected one watch rule, got %d in\n%s", n, got)
+			}
+			if generated := strings.Contains(got, "$(MAKE) --no-print-directory $(TARGET) SAGE_WATCH=true"); generated != tt.generated {
+				t.Errorf("expected generated watch rule to be %v, got\n%s", tt.generated, got)
+			}
+		})
+	}
+}
+
+// ProtoFields is a namespace with fields, which has to be exported to have targets.
+type ProtoFields struct {
+	Namespace
+	Dir   string `sage:"PROTO_DIR"`
+	Langs []string
+}
+
+func TestGenerateFiles_NamespaceVars(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, `package main
+
+import (
+	"context"
+
+	"go.einride.tech/sage/sg"
+)
+
+type ProtoFields sg.Namespace
+
+func (ProtoFields) Gen(ctx context.Context, lang string) error { return nil }
+`)
+	mk := Makefile{
+		Path:      FromGitRoot("proto", "Makefile"),
+		Justfile:  FromGitRoot("proto", "justfile"),
+		Taskfile:  FromGitRoot("proto", "Taskfile.yml"),
+		Namespace: ProtoFields{Dir: "proto", Langs: []string{"go", "python"}},
+	}
+	makefile := codegen.NewMakefile(codegen.FileConfig{})
+	if err := generateMakefile(context.Background(), makefile, pkg, mk, mk); err != nil {
+		t.Fatal(err)
+	}
+	justfile := codegen.NewMakefile(codegen.FileConfig{})
+	if err := generateJustfile(justfile, pkg, mk, mk); err != nil {
+		t.Fatal(err)
+	}
+	taskfile := codegen.NewMakefile(codegen.FileConfig{})
+	if err := generateTaskfile(taskfile, pkg, mk, mk); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		got      string
+		expected []string
+	}{
+		{
+			name: "Makefile",
+			got:  string(makefile.RawContent()),
+			expected: []string{
+				"SAGE_PROTO_DIR ?= proto\nSAGE_PROTO_FIELDS_LANGS ?= go,python\n",
+				"\t@$(sagefile) ProtoFields:Gen \"SAGE_PROTO_DIR=$(SAGE_PROTO_DIR)\" " +
+					"\"SAGE_PROTO_FIELDS_LANGS=$(SAGE_PROTO_FIELDS_LANGS)\"",
+			},
+		},
+		{
+			name: "justfile",
+			got:  string(justfile.RawContent()),
+			expected: []string{
+				"SAGE_PROTO_DIR := env('SAGE_PROTO_DIR', 'proto')\n" +
+					"SAGE_PROTO_FIELDS_LANGS := env('SAGE_PROTO_FIELDS_LANGS', 'go,python')\n",
+				"\t@{{quote(sagefile)}} ProtoFields:Gen {{quote(\"SAGE_PROTO_DIR=\" + SAGE_PROTO_DIR)}} " +
+					"{{quote(\"SAGE_PROTO_FIELDS_LANGS=\" + SAGE_PROTO_FIELDS_LANGS)}}",
+			},
+		},
+		{
+			name: "Taskfile",
+			got:  string(taskfile.RawContent()),
+			expected: []string{
+				"  SAGE_PROTO_DIR: '{{.SAGE_PROTO_DIR | default \"proto\"}}'\n" +
+					"  SAGE_PROTO_FIELDS_LANGS: '{{.SAGE_PROTO_FIELDS_LANGS | default \"go,python\"}}'\n",
+				`      - '"{{.SAGEFILE}}" ProtoFields:Gen "SAGE_PROTO_DIR={{.SAGE_PROTO_DIR}}" ` +
+					`"SAGE_PROTO_FIELDS_LANGS={{.SAGE_PROTO_FIELDS_LANGS}}"`,
+			},
+		},
+	} {
+		for _, expected := range tt.expected {
+			if !strings.Contains(tt.got, expected) {
+				t.Errorf("expected %s to contain\n%s\nbut got\n%s", tt.name, expected, tt.got)
+			}
+		}
+		if strings.Contains(tt.got, "export SAGE_") {
+			t.Errorf("expected %s to not export the namespace variables, got\n%s", tt.name, tt.got)
+		}
+	}
+}
@@ -1,4 +1,211 @@
 package sg
 
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.einride.tech/sage/internal/strcase"
+)
+
 // Namespace allows for the grouping of similar commands.
 type Namespace struct{}
+
+// namespaceVarPrefix is the prefix of the names of namespace variables, to keep them apart from other variables.
+const namespaceVarPrefix = "SAGE_"
+
+// SetNamespaceFields sets the fields of the namespace struct pointed to by namespace from the leading NAME=value
+// arguments of args, and returns the remaining arguments. Fields that are not set by args are set from the
+// environment variable NAME, if it is set.
+//
+// The generated Makefiles, justfiles and Taskfiles pass their namespace variables on the command line of the
+// sagefile, defaulting them to the environment, so fields can be set with "make <target> SAGE_PROTO_DIR=api" or
+// "SAGE_PROTO_DIR=api make <target>". By default, a field is set from a variable
+// named after the namespace type and the field in upper snake case with the prefix SAGE_, such as SAGE_PROTO_DIR for
+// the field Dir of the namespace Proto, and nested struct fields add their name to the variable name of their parent,
+// such as SAGE_PROTO_CONFIG_REGION. The struct tag `sage:"NAME"` names the variable of a field without the prefix,
+// and `sage:"-"` keeps it from being set.
+//
+// Fields of type string, bool, int, uint, float, time.Duration, types implementing encoding.TextUnmarshaler and
+// slices of them can be set. Slices are set from comma-separated values.
+//
+// SetNamespaceFields is called by the generated sagefile entrypoint, and should not be called from sagefiles.
+func SetNamespaceFields(namespace any, args []string) ([]string, error) {
+	v := reflect.ValueOf(namespace)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("namespace must be a pointer to a struct, got %T", namespace)
+	}
+	vars := namespaceVars(v.Elem())
+	set := make([]bool, len(vars))
+	for len(args) > 0 {
+		name, value, ok := strings.Cut(args[0], "=")
+		if !ok {
+			break
+		}
+		i := slices.IndexFunc(vars, func(v namespaceVar) bool { return v.name == name })
+		if i == -1 {
+			break
+		}
+		if err := parseNamespaceValue(vars[i].value, value); err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+		}
+		set[i] = true
+		args = args[1:]
+	}
+	for i, v := range vars {
+		if set[i] {
+			continue
+		}
+		if value, ok := os.LookupEnv(v.name); ok {
+			if err := parseNamespaceValue(v.value, value); err != nil {
+				return nil, fmt.Errorf("invalid value %q for environment variable %s: %w", value, v.name, err)
+			}
+		}
+	}
+	return args, nil
+}
+
+// namespaceVar is a namespace field that can be set from a variable.
+type namespaceVar struct {
+	name  string
+	value reflect.Value
+}
+
+// namespaceVars returns the fields of the namespace struct v that can be set from variables.
+func namespaceVars(v reflect.Value) []namespaceVar {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	result := appendNamespaceVars(nil, v, strings.ToUpper(strcase.ToSnake(v.Type().Name())))
+	for i := range result {
+		result[i].name = namespaceVarPrefix + result[i].name
+	}
+	return result
+}
+
+func appendNamespaceVars(result []namespaceVar, v reflect.Value, prefix string) []namespaceVar {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Type == reflect.TypeFor[Namespace]() {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(strcase.ToSnake(field.Name))
+		if tag, ok := field.Tag.Lookup("sage"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		switch {
+		case isNamespaceVarType(field.Type):
+			result = append(result, namespaceVar{name: name, value: v.Field(i)})
+		case field.Type.Kind() == reflect.Struct:
+			result = appendNamespaceVars(result, v.Field(i), name)
+		}
+	}
+	return result
+}
+
+func isNamespaceVarType(t reflect.Type) bool {
+	if isTextUnmarshaler(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Slice && isNamespaceVarType(t.Elem())
+	default:
+		return false
+	}
+}
+
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]())
+}
+
+// parseNamespaceValue parses s into the settable value v.
+func parseNamespaceValue(v reflect.Value, s string) error {
+	if isTextUnmarshaler(v.Type()) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == reflect.TypeFor[time.Duration]() {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var elems []string
+		if s != "" {
+			elems = strings.Split(s, ",")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if err := parseNamespaceValue(slice.Index(i), elem); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// formatNamespaceValue formats v in the format parsed by parseNamespaceValue.
+func formatNamespaceValue(v reflect.Value) string {
+	if marshaler, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if text, err := marshaler.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	if v.Kind() == reflect.Slice {
+		elems := make([]string, 0, v.Len())
+		for i := range v.Len() {
+			elems = append(elems, formatNamespaceValue(v.Index(i)))
+		}
+		return strings.Join(elems, ",")
+	}
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		return time.Duration(v.Int()).String()
+	}
+	return fmt.Sprint(v.Interface())
+}
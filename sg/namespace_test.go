@@ -0,0 +1,149 @@
+package sg
+
+import (
+	"net/netip"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+type testNamespace struct {
+	Namespace
+	Dir     string
+	Labels  []string `sage:"LABELS"`
+	Timeout time.Duration
+	Retries uint8
+	Verbose bool
+	Addr    netip.Addr
+	Since   time.Time
+	Config  testNamespaceConfig
+	Secret  string `sage:"-"`
+}
+
+type testNamespaceConfig struct {
+	Region string
+	Ratios []float64
+}
+
+func TestSetNamespaceFields(t *testing.T) {
+	t.Parallel()
+	t.Run("args", func(t *testing.T) {
+		t.Parallel()
+		ns := testNamespace{Dir: "proto", Secret: "secret", Config: testNamespaceConfig{Region: "eu"}}
+		args, err := SetNamespaceFields(&ns, []string{
+			"SAGE_TEST_NAMESPACE_DIR=api",
+			"SAGE_LABELS=a,b",
+			"SAGE_TEST_NAMESPACE_TIMEOUT=5s",
+			"SAGE_TEST_NAMESPACE_RETRIES=3",
+			"SAGE_TEST_NAMESPACE_VERBOSE=true",
+			"SAGE_TEST_NAMESPACE_ADDR=127.0.0.1",
+			"SAGE_TEST_NAMESPACE_CONFIG_RATIOS=0.5,1",
+			"positional",
+			"SAGE_TEST_NAMESPACE_SECRET=ignored",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := testNamespace{
+			Dir:     "api",
+			Labels:  []string{"a", "b"},
+			Timeout: 5 * time.Second,
+			Retries: 3,
+			Verbose: true,
+			Addr:    netip.MustParseAddr("127.0.0.1"),
+			Config:  testNamespaceConfig{Region: "eu", Ratios: []float64{0.5, 1}},
+			Secret:  "secret",
+		}
+		if !reflect.DeepEqual(ns, expected) {
+			t.Errorf("expected %+v but got %+v", expected, ns)
+		}
+		if expectedArgs := []string{"positional", "SAGE_TEST_NAMESPACE_SECRET=ignored"}; !slices.Equal(args, expectedArgs) {
+			t.Errorf("expected remaining args %q but got %q", expectedArgs, args)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		var ns testNamespace
+		const errorMessage = `invalid value "300" for SAGE_TEST_NAMESPACE_RETRIES: ` +
+			`strconv.ParseUint: parsing "300": value out of range`
+		if _, err := SetNamespaceFields(&ns, []string{"SAGE_TEST_NAMESPACE_RETRIES=300"}); err == nil ||
+			err.Error() != errorMessage {
+			t.Errorf("expected error %q but got %v", errorMessage, err)
+		}
+	})
+}
+
+func TestSetNamespaceFields_Environment(t *testing.T) {
+	t.Setenv("SAGE_TEST_NAMESPACE_DIR", "env")
+	t.Setenv("SAGE_TEST_NAMESPACE_RETRIES", "2")
+	t.Setenv("SAGE_TEST_NAMESPACE_SECRET", "ignored")
+	ns := testNamespace{Dir: "proto", Secret: "secret", Verbose: true}
+	// Args take precedence over the environment, which takes precedence over the values of the namespace.
+	if _, err := SetNamespaceFields(&ns, []string{"SAGE_TEST_NAMESPACE_RETRIES=3"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := testNamespace{Dir: "env", Secret: "secret", Verbose: true, Retries: 3}
+	if !reflect.DeepEqual(ns, expected) {
+		t.Errorf("expected %+v but got %+v", expected, ns)
+	}
+	t.Setenv("SAGE_TEST_NAMESPACE_RETRIES", "many")
+	const errorMessage = `invalid value "many" for environment variable SAGE_TEST_NAMESPACE_RETRIES: ` +
+		`strconv.ParseUint: parsing "many": invalid syntax`
+	if _, err := SetNamespaceFields(&ns, nil); err == nil || err.Error() != errorMessage {
+		t.Errorf("expected error %q but got %v", errorMessage, err)
+	}
+}
+
+func TestNamespaceVars(t *testing.T) {
+	t.Parallel()
+	ns := testNamespace{Dir: "proto", Labels: []string{"a", "b"}, Timeout: time.Minute}
+	var got []string
+	for _, v := range namespaceVars(reflect.ValueOf(ns)) {
+		got = append(got, v.name+"="+formatNamespaceValue(v.value))
+	}
+	expected := []string{
+		"SAGE_TEST_NAMESPACE_DIR=proto",
+		"SAGE_LABELS=a,b",
+		"SAGE_TEST_NAMESPACE_TIMEOUT=1m0s",
+		"SAGE_TEST_NAMESPACE_RETRIES=0",
+		"SAGE_TEST_NAMESPACE_VERBOSE=false",
+		"SAGE_TEST_NAMESPACE_ADDR=",
+		"SAGE_TEST_NAMESPACE_SINCE=0001-01-01T00:00:00Z",
+		"SAGE_TEST_NAMESPACE_CONFIG_REGION=",
+		"SAGE_TEST_NAMESPACE_CONFIG_RATIOS=",
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %q but got %q", expected, got)
+	}
+}
+
+func TestGoLiteral(t *testing.T) {
+	t.Parallel()
+	g := codegen.NewFile(codegen.FileConfig{Package: "main"})
+	ns := testNamespace{
+		Dir:     "proto",
+		Labels:  []string{"a", "b"},
+		Timeout: time.Minute,
+		Addr:    netip.MustParseAddr("10.0.0.1"),
+		Since:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Config:  testNamespaceConfig{Ratios: []float64{0.5}},
+	}
+	const expected = `sg.testNamespace{Dir: "proto", Labels: []string{"a", "b"}, Timeout: time.Duration(60000000000), ` +
+		`Addr: func() (v netip.Addr) {if err := v.UnmarshalText([]byte("10.0.0.1")); err != nil { panic(err) }; ` +
+		`return v }(), ` +
+		`Since: func() (v time.Time) {if err := v.UnmarshalText([]byte("2024-01-02T03:04:05Z")); err != nil { ` +
+		`panic(err) }; return v }(), ` +
+		`Config: sg.testNamespaceConfig{Ratios: []float64{0.5}}}`
+	got := goLiteral(g, reflect.ValueOf(ns))
+	if got != expected {
+		t.Errorf("expected %s but got %s", expected, got)
+	}
+	// The literal must be valid Go.
+	g.P("var _ = ", got)
+	if _, err := g.GoContent(); err != nil {
+		t.Error(err)
+	}
+}
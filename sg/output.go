@@ -0,0 +1,147 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// targetOutput buffers the output of a target run in grouped output mode.
+//
+// The output is buffered as chunks written to stdout or stderr, in the order they were written, so that the output
+// can be written to the same stream as it would have been without buffering.
+type targetOutput struct {
+	mu     sync.Mutex
+	chunks []outputChunk
+}
+
+// outputChunk is output written to w.
+type outputChunk struct {
+	w    io.Writer
+	data []byte
+}
+
+func (o *targetOutput) write(w io.Writer, p []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if n := len(o.chunks); n > 0 && o.chunks[n-1].w == w {
+		o.chunks[n-1].data = append(o.chunks[n-1].data, p...)
+		return
+	}
+	o.chunks = append(o.chunks, outputChunk{w: w, data: bytes.Clone(p)})
+}
+
+func (o *targetOutput) snapshot() []outputChunk {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	result := make([]outputChunk, 0, len(o.chunks))
+	for _, chunk := range o.chunks {
+		result = append(result, outputChunk{w: chunk.w, data: bytes.Clone(chunk.data)})
+	}
+	return result
+}
+
+// targetOutputWriter writes the output of a target run to w into the buffer of the run.
+type targetOutputWriter struct {
+	output *targetOutput
+	w      io.Writer
+}
+
+// Write implements io.Writer.
+func (t targetOutputWriter) Write(p []byte) (int, error) {
+	t.output.write(t.w, p)
+	return len(p), nil
+}
+
+type targetOutputContextKey struct{}
+
+// global state for grouped output.
+//
+//nolint:gochecknoglobals
+var (
+	outputMu            sync.Mutex
+	failedTargetOutputs []failedTargetOutput
+)
+
+type failedTargetOutput struct {
+	name   string
+	chunks []outputChunk
+}
+
+// isGroupedOutput reports whether grouped output mode is enabled with SAGE_OUTPUT=grouped.
+//
+// In grouped output mode, the output of each target is buffered and written as one block when the target finishes,
+// so that the output of targets running in parallel is not interleaved. The output of failed targets is written
+// last, when the run finishes.
+func isGroupedOutput() bool {
+	return os.Getenv("SAGE_OUTPUT") == "grouped"
+}
+
+// withTargetOutput returns a context where output of the target run is buffered, if grouped output mode is enabled.
+func withTargetOutput(ctx context.Context) (context.Context, *targetOutput) {
+	if !isGroupedOutput() {
+		return ctx, nil
+	}
+	output := &targetOutput{}
+	return context.WithValue(ctx, targetOutputContextKey{}, output), output
+}
+
+// outputWriter returns the writer for output of the target run of ctx to w, or w if output is not buffered.
+func outputWriter(ctx context.Context, w io.Writer) io.Writer {
+	if output, ok := ctx.Value(targetOutputContextKey{}).(*targetOutput); ok {
+		return targetOutputWriter{output: output, w: w}
+	}
+	return w
+}
+
+// flushTargetOutput writes the buffered output of a finished target run.
+// The output of failed targets is held back until the run finishes, see flushFailedTargetOutputs.
+func flushTargetOutput(name string, output *targetOutput, err error) {
+	if output == nil {
+		return
+	}
+	chunks := output.snapshot()
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if err != nil {
+		failedTargetOutputs = append(failedTargetOutputs, failedTargetOutput{name: name, chunks: chunks})
+		return
+	}
+	if len(chunks) == 0 {
+		return
+	}
+	writeOutputBlock(os.Stderr, name, chunks, isGitHubActions())
+}
+
+// flushFailedTargetOutputs writes the buffered output of failed target runs, without collapsible groups.
+func flushFailedTargetOutputs() {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	for _, failed := range failedTargetOutputs {
+		writeOutputBlock(os.Stderr, failed.name, failed.chunks, false)
+	}
+	failedTargetOutputs = nil
+}
+
+// writeOutputBlock writes the output chunks of a target run to their writers, optionally as a collapsible group in
+// GitHub Actions logs, with the group commands written to w.
+//
+// See: https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions#grouping-log-lines
+func writeOutputBlock(w io.Writer, name string, chunks []outputChunk, group bool) {
+	if group {
+		_, _ = fmt.Fprintf(w, "::group::%s\n", loggerPrefix(name))
+	}
+	for _, chunk := range chunks {
+		_, _ = chunk.w.Write(chunk.data)
+	}
+	if group {
+		_, _ = fmt.Fprintln(w, "::endgroup::")
+	}
+}
+
+func isGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
@@ -0,0 +1,118 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGroupedOutput_NestedFailure(t *testing.T) {
+	if os.Getenv("SAGE_TEST_GROUPED_OUTPUT") == "true" {
+		err := RunMain(context.Background(), "main.Build", func(ctx context.Context) error {
+			Logger(ctx).Println("build output")
+			Deps(ctx, outputTestParent, outputTestSibling)
+			return nil
+		})
+		if err == nil {
+			os.Exit(0)
+		}
+		NewLogger("sagefile").Println(err)
+		os.Exit(1)
+	}
+	t.Parallel()
+	dir := t.TempDir()
+	if err := exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Fatal(err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(executable, "-test.run=^TestGroupedOutput_NestedFailure$")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "SAGE_TEST_GROUPED_OUTPUT=true", "SAGE_OUTPUT=grouped", "SAGE_SUMMARY=false")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1, got %v\n%s", err, stderr.String())
+	}
+	// The output of successful targets is written when they finish, and the output of failed targets, including the
+	// targets that failed because their dependencies failed, is written when the run finishes.
+	expected := []string{
+		"sibling output",
+		"child output",
+		"parent output",
+		"build output",
+		"failed dependencies: sg:output-test-parent",
+	}
+	output := stderr.String()
+	var last int
+	for _, line := range expected {
+		i := strings.Index(output, line)
+		if i < last {
+			t.Fatalf("expected %q in order %q, got:\n%s", line, expected, output)
+		}
+		last = i
+	}
+}
+
+func outputTestParent(ctx context.Context) error {
+	Logger(ctx).Println("parent output")
+	Deps(ctx, outputTestChild)
+	return nil
+}
+
+func outputTestChild(ctx context.Context) error {
+	Logger(ctx).Println("child output")
+	return errors.New("child failed")
+}
+
+func outputTestSibling(ctx context.Context) error {
+	Logger(ctx).Println("sibling output")
+	return nil
+}
+
+func TestGroupedOutput_Panic(t *testing.T) {
+	if os.Getenv("SAGE_TEST_GROUPED_OUTPUT_PANIC") == "true" {
+		_ = RunMain(context.Background(), "main.Build", func(ctx context.Context) error {
+			Logger(ctx).Println("about to panic")
+			if err := Command(ctx, "echo", "command stdout").Run(); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+		os.Exit(0)
+	}
+	t.Parallel()
+	dir := t.TempDir()
+	if err := exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Fatal(err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(executable, "-test.run=^TestGroupedOutput_Panic$")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "SAGE_TEST_GROUPED_OUTPUT_PANIC=true", "SAGE_OUTPUT=grouped", "SAGE_SUMMARY=false")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("expected the panic to fail the run\n%s", stderr.String())
+	}
+	// The output of the panicking target is written before the panic, to the stream it was written to.
+	if !strings.Contains(stderr.String(), "about to panic") {
+		t.Errorf("expected the log output of the panicking target on stderr, got:\n%s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "command stdout") {
+		t.Errorf("expected the stdout of the command on stdout, got:\n%s", stdout.String())
+	}
+	if strings.Contains(stderr.String(), "command stdout") {
+		t.Errorf("expected the stdout of the command not to be on stderr, got:\n%s", stderr.String())
+	}
+}
@@ -0,0 +1,128 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+type (
+	parallelismContextKey struct{}
+	heldSlotContextKey    struct{}
+)
+
+// globalSemaphore limits parallelism when no limit has been attached to the context.
+//
+//nolint:gochecknoglobals
+var globalSemaphore = sync.OnceValue(func() *semaphore {
+	value, ok := os.LookupEnv("SAGE_MAX_PARALLELISM")
+	if !ok || value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		panic(fmt.Sprintf("invalid SAGE_MAX_PARALLELISM %q: must be a positive integer", value))
+	}
+	return newSemaphore(n)
+})
+
+// WithMaxParallelism returns a context where at most n targets started by Deps run at the same time.
+//
+// The limit applies across nested calls to Deps. A target that waits for its own dependencies does not count
+// towards the limit while it waits.
+//
+// The limit can also be set for a whole run with the environment variable SAGE_MAX_PARALLELISM.
+func WithMaxParallelism(ctx context.Context, n int) context.Context {
+	if n < 1 {
+		panic(fmt.Sprintf("max parallelism must be positive, got %d", n))
+	}
+	return context.WithValue(ctx, parallelismContextKey{}, newSemaphore(n))
+}
+
+type semaphore struct {
+	slots chan struct{}
+}
+
+func newSemaphore(n int) *semaphore {
+	return &semaphore{slots: make(chan struct{}, n)}
+}
+
+func (s *semaphore) acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *semaphore) release() {
+	<-s.slots
+}
+
+func getSemaphore(ctx context.Context) *semaphore {
+	if s, ok := ctx.Value(parallelismContextKey{}).(*semaphore); ok {
+		return s
+	}
+	return globalSemaphore()
+}
+
+// heldSlot is a slot from a parallelism limit held by a running target.
+type heldSlot struct {
+	s      *semaphore
+	mu     sync.Mutex
+	held   bool
+	yields int
+}
+
+// release gives up the slot, if it is held.
+func (h *heldSlot) release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.held {
+		h.s.release()
+		h.held = false
+	}
+}
+
+// withSlot acquires a slot from the parallelism limit of ctx and returns a context marking it as held.
+func withSlot(ctx context.Context) (context.Context, func(), error) {
+	s := getSemaphore(ctx)
+	if s == nil {
+		return ctx, func() {}, nil
+	}
+	if err := s.acquire(ctx); err != nil {
+		return ctx, func() {}, err
+	}
+	h := &heldSlot{s: s, held: true}
+	return context.WithValue(ctx, heldSlotContextKey{}, h), h.release, nil
+}
+
+// yieldSlot temporarily gives up the slot held by ctx, if any, and returns a function to take it back.
+// This allows a running target to wait for its own dependencies without exhausting the limit.
+//
+// A target may wait for dependencies from several goroutines at once. The slot is given up by the first of them and
+// taken back when the last of them is done, or not at all if ctx is cancelled while waiting for it.
+func yieldSlot(ctx context.Context) func() {
+	h, ok := ctx.Value(heldSlotContextKey{}).(*heldSlot)
+	if !ok {
+		return func() {}
+	}
+	h.mu.Lock()
+	if h.yields == 0 && h.held {
+		h.s.release()
+		h.held = false
+	}
+	h.yields++
+	h.mu.Unlock()
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.yields--
+		if h.yields == 0 && !h.held && h.s.acquire(ctx) == nil {
+			h.held = true
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package sg
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDeps_MaxParallelism(t *testing.T) {
+	ctx := WithMaxParallelism(context.Background(), 1)
+	var running, maxRunning atomic.Int32
+	leaf := func(name string) Target {
+		return fn{name: name, id: t.Name() + name, f: func(context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			if n > maxRunning.Load() {
+				maxRunning.Store(n)
+			}
+			return nil
+		}}
+	}
+	parent := func(name string, deps ...any) Target {
+		return fn{name: name, id: t.Name() + name, f: func(ctx context.Context) error {
+			// A parent waiting for its own dependencies must not deadlock on the limit.
+			Deps(ctx, deps...)
+			return nil
+		}}
+	}
+	Deps(ctx, parent("a", leaf("x"), leaf("y")), parent("b", leaf("x"), leaf("z")), leaf("w"))
+	if got := maxRunning.Load(); got > 1 {
+		t.Fatalf("expected at most 1 running leaf, got %d", got)
+	}
+}
+
+func TestYieldSlot_Concurrent(t *testing.T) {
+	t.Parallel()
+	ctx := WithMaxParallelism(context.Background(), 2)
+	s := getSemaphore(ctx)
+	ctx, release, err := withSlot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Several goroutines of the same target yield its slot at once.
+	reacquire := make([]func(), 3)
+	for i := range reacquire {
+		reacquire[i] = yieldSlot(ctx)
+	}
+	if got := len(s.slots); got != 0 {
+		t.Fatalf("expected the slot to be yielded once, got %d slots in use", got)
+	}
+	for _, f := range reacquire {
+		f()
+	}
+	if got := len(s.slots); got != 1 {
+		t.Fatalf("expected the slot to be taken back once, got %d slots in use", got)
+	}
+	release()
+	if got := len(s.slots); got != 0 {
+		t.Fatalf("expected the slot to be released, got %d slots in use", got)
+	}
+}
+
+func TestYieldSlot_Cancelled(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(WithMaxParallelism(context.Background(), 1))
+	s := getSemaphore(ctx)
+	ctx, release, err := withSlot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reacquire := yieldSlot(ctx)
+	// Another target takes the slot, so the slot can not be taken back until ctx is cancelled.
+	if err := s.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	reacquire()
+	release()
+	if got := len(s.slots); got != 1 {
+		t.Fatalf("expected only the slot of the other target to be in use, got %d", got)
+	}
+}
@@ -0,0 +1,216 @@
+package sg
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+const (
+	float64Type     = "float64"
+	durationType    = "time.Duration"
+	stringSliceType = "[]string"
+)
+
+// paramKind is the kind of a custom target function parameter, which decides how it is parsed from a Make variable.
+type paramKind int
+
+const (
+	unsupportedParam paramKind = iota
+	stringParam
+	intParam
+	boolParam
+	float64Param
+	durationParam
+	stringSliceParam
+	// namedStringParam is a named string type declared in the sagefile. If constants of the type are declared,
+	// they are the allowed values.
+	namedStringParam
+	// textUnmarshalerParam is a type that implements encoding.TextUnmarshaler with a pointer receiver, such as
+	// netip.Addr or a type declared in the sagefile with an UnmarshalText method.
+	textUnmarshalerParam
+)
+
+// customParamKind returns the kind of a custom target function parameter of type expr.
+//
+// The kind is decided by the type of expr, so that types are recognized through renamed imports and type aliases.
+// If the type is not known, the kind is decided by how the type is written.
+func customParamKind(pkg *sagePackage, expr ast.Expr) paramKind {
+	t := pkg.typeOf(expr)
+	typeName := types.ExprString(expr)
+	if t != nil {
+		// Qualify types by the names of their packages, rather than by the names of the imports in the sagefiles.
+		typeName = types.TypeString(types.Unalias(t), func(p *types.Package) string { return p.Name() })
+	}
+	switch typeName {
+	case stringType:
+		return stringParam
+	case intType:
+		return intParam
+	case boolType:
+		return boolParam
+	case float64Type:
+		return float64Param
+	case durationType:
+		return durationParam
+	case stringSliceType:
+		return stringSliceParam
+	}
+	if t != nil && types.Implements(types.NewPointer(t), textUnmarshalerType) {
+		return textUnmarshalerParam
+	}
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return unsupportedParam
+	}
+	docType := findType(pkg, ident.Name)
+	if docType == nil {
+		return unsupportedParam
+	}
+	if typeSpec, ok := docType.Decl.Specs[0].(*ast.TypeSpec); ok && types.ExprString(typeSpec.Type) == stringType {
+		return namedStringParam
+	}
+	return unsupportedParam
+}
+
+// textUnmarshalerType is the type of encoding.TextUnmarshaler.
+//
+//nolint:gochecknoglobals
+var textUnmarshalerType = types.NewInterfaceType([]*types.Func{
+	types.NewFunc(token.NoPos, nil, "UnmarshalText", types.NewSignatureType(
+		nil,
+		nil,
+		nil,
+		types.NewTuple(types.NewVar(token.NoPos, nil, "text", types.NewSlice(types.Typ[types.Byte]))),
+		types.NewTuple(types.NewVar(token.NoPos, nil, "", types.Universe.Lookup("error").Type())),
+		false,
+	)),
+}, nil).Complete()
+
+// paramTypeName returns the name of the type of a target function parameter in the generated sagefile entrypoint.
+func paramTypeName(g *codegen.File, pkg *sagePackage, expr ast.Expr) string {
+	named, ok := types.Unalias(pkg.typeOf(expr)).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || pkg.declares(expr) {
+		return types.ExprString(expr)
+	}
+	return g.Import(named.Obj().Pkg().Path()) + "." + named.Obj().Name()
+}
+
+func findType(pkg *sagePackage, name string) *doc.Type {
+	if pkg == nil {
+		return nil
+	}
+	for _, t := range pkg.Types {
+		if t.Name == name && len(t.Decl.Specs) == 1 {
+			return t
+		}
+	}
+	return nil
+}
+
+// typeConstNames returns the names of the constants declared with the type t, in declaration order.
+func typeConstNames(t *doc.Type) []string {
+	var result []string
+	for _, value := range t.Consts {
+		for _, spec := range value.Decl.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for _, name := range valueSpec.Names {
+				if ast.IsExported(name.Name) {
+					result = append(result, name.Name)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// typeConstValues returns the values of the string constants declared with the type t, in declaration order.
+// Constants with values that are not string literals are skipped.
+func typeConstValues(t *doc.Type) []string {
+	var result []string
+	for _, value := range t.Consts {
+		for _, spec := range value.Decl.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for i, name := range valueSpec.Names {
+				if !ast.IsExported(name.Name) || i >= len(valueSpec.Values) {
+					continue
+				}
+				lit, ok := valueSpec.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				if s, err := strconv.Unquote(lit.Value); err == nil {
+					result = append(result, s)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// generateParamParser generates code that parses the command line argument args[i] into the variable arg<i>.
+// Parse errors name the Make variable makeVar that the argument is passed in.
+func generateParamParser(g *codegen.File, pkg *sagePackage, expr ast.Expr, i int, makeVar string) {
+	arg := "args[" + strconv.Itoa(i) + "]"
+	fatal := func(format, formatArgs string) {
+		g.P(`logger.Printf("invalid value %q for `, makeVar, ": ", format, `", `, arg, formatArgs, ")")
+		g.P(g.Import("os"), ".Exit(", usageExitCode, ")")
+	}
+	switch customParamKind(pkg, expr) {
+	case stringParam:
+		g.P("arg", i, " := ", arg)
+	case intParam:
+		g.P("arg", i, ", err := ", g.Import("strconv"), ".Atoi(", arg, ")")
+		g.P("if err != nil {")
+		fatal("not an int", "")
+		g.P("}")
+	case boolParam:
+		g.P("arg", i, ", err := ", g.Import("strconv"), ".ParseBool(", arg, ")")
+		g.P("if err != nil {")
+		fatal("not a bool", "")
+		g.P("}")
+	case float64Param:
+		g.P("arg", i, ", err := ", g.Import("strconv"), ".ParseFloat(", arg, ", 64)")
+		g.P("if err != nil {")
+		fatal("not a number", "")
+		g.P("}")
+	case durationParam:
+		g.P("arg", i, ", err := ", g.Import("time"), ".ParseDuration(", arg, ")")
+		g.P("if err != nil {")
+		fatal("%v", ", err")
+		g.P("}")
+	case stringSliceParam:
+		g.P("var arg", i, " []string")
+		g.P("if ", arg, ` != "" {`)
+		g.P("arg", i, " = ", g.Import("strings"), ".Split(", arg, `, ",")`)
+		g.P("}")
+	case namedStringParam:
+		typeName := types.ExprString(expr)
+		g.P("arg", i, " := ", typeName, "(", arg, ")")
+		if consts := typeConstNames(findType(pkg, typeName)); len(consts) > 0 {
+			g.P("switch arg", i, " {")
+			g.P("case ", strings.Join(consts, ", "), ":")
+			g.P("default:")
+			fatal("must be one of %v", ", []"+typeName+"{"+strings.Join(consts, ", ")+"}")
+			g.P("}")
+		}
+	case textUnmarshalerParam:
+		g.P("var arg", i, " ", paramTypeName(g, pkg, expr))
+		g.P("if err := arg", i, ".UnmarshalText([]byte(", arg, ")); err != nil {")
+		fatal("%v", ", err")
+		g.P("}")
+	case unsupportedParam:
+		panic("unsupported target function parameter type: " + types.ExprString(expr))
+	}
+}
@@ -0,0 +1,138 @@
+package sg
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+const paramsTestSagefile = `package main
+
+import (
+	"context"
+	"net/netip"
+	nip "net/netip"
+	"time"
+	tm "time"
+)
+
+type Env string
+
+const (
+	EnvDev  Env = "dev"
+	EnvProd Env = "prod"
+)
+
+type Name string
+
+type Level int
+
+func (l *Level) UnmarshalText(text []byte) error { return nil }
+
+type Config struct{}
+
+type Timeout = time.Duration
+
+func Deploy(
+	ctx context.Context,
+	s string,
+	i int,
+	b bool,
+	f float64,
+	d time.Duration,
+	ss []string,
+	env Env,
+	name Name,
+	level Level,
+	config Config,
+	addr netip.Addr,
+	prefix nip.Prefix,
+	is []int,
+	td tm.Duration,
+	timeout Timeout,
+) error {
+	return nil
+}
+`
+
+func TestCustomParamKind(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, paramsTestSagefile)
+	expected := map[string]paramKind{
+		"s":       stringParam,
+		"i":       intParam,
+		"b":       boolParam,
+		"f":       float64Param,
+		"d":       durationParam,
+		"ss":      stringSliceParam,
+		"env":     namedStringParam,
+		"name":    namedStringParam,
+		"level":   textUnmarshalerParam,
+		"config":  unsupportedParam,
+		"addr":    textUnmarshalerParam,
+		"prefix":  textUnmarshalerParam,
+		"is":      unsupportedParam,
+		"td":      durationParam,
+		"timeout": durationParam,
+	}
+	for _, param := range pkg.Funcs[0].Decl.Type.Params.List[1:] {
+		name := param.Names[0].Name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if got := customParamKind(pkg, param.Type); got != expected[name] {
+				t.Errorf("expected %v but got %v", expected[name], got)
+			}
+		})
+	}
+	if got, expected := typeConstNames(findType(pkg, "Env")), []string{"EnvDev", "EnvProd"}; len(got) != 2 ||
+		got[0] != expected[0] || got[1] != expected[1] {
+		t.Errorf("expected allowed values %v but got %v", expected, got)
+	}
+}
+
+func TestSkippedTargets(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, paramsTestSagefile+`
+type Tools sg.Namespace
+
+func (Tools) Install(ctx context.Context, versions map[string]string) error { return nil }
+
+func (Tools) Addr(ctx context.Context, addr netip.Addr) error { return nil }
+
+func helper(ctx context.Context, is []int) error { return nil }
+
+func NotATarget(s string) error { return nil }
+`)
+	expected := []string{
+		"Deploy: unsupported parameter type Config",
+		"Tools.Install: unsupported parameter type map[string]string",
+	}
+	if got := skippedTargets(pkg); !slices.Equal(got, expected) {
+		t.Errorf("expected skipped targets %q, got %q", expected, got)
+	}
+}
+
+func TestGenerateParamParser_ImportedTextUnmarshaler(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, paramsTestSagefile)
+	g := codegen.NewFile(codegen.FileConfig{Filename: "generating_sagefile.go", Package: "main"})
+	g.P("func parse(args []string, logger *", g.Import("log"), ".Logger) {")
+	for i, param := range pkg.Funcs[0].Decl.Type.Params.List[1:] {
+		switch param.Names[0].Name {
+		case "level", "addr", "prefix":
+			generateParamParser(g, pkg, param.Type, i, "ARG")
+		}
+	}
+	g.P("}")
+	content, err := g.GoContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"net/netip"`, "var arg8 Level", "var arg10 netip.Addr", "var arg11 netip.Prefix"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("expected generated code to contain %q, got:\n%s", expected, content)
+		}
+	}
+}
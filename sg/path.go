@@ -15,6 +15,17 @@ const (
 	binDir         = "bin"
 	buildDir       = "build"
 	sageFileBinary = "sagefile"
+	// sageFileStamp is the file next to the sagefile with the hash of the files the sagefile was built from.
+	sageFileStamp = sageFileBinary + ".stamp"
+	// sageFileInputs is the file next to the sagefile with the local directories that the sagefile was built from,
+	// see writeSageFileInputs.
+	sageFileInputs = sageFileBinary + ".inputs"
+	// sageFileHashCommand is a shell command that prints a hash of the sagefiles, go.mod and go.sum, and of the Go
+	// files and go.mod files in the directories of the inputs file, when run in the sage directory. The generated files
+	// compare its output with the stamp to decide whether to rebuild the sagefile.
+	sageFileHashCommand = "{ ls *.go; cat go.mod go.sum *.go; " +
+		`xargs -I{} sh -c 'ls "{}"/*.go; cat "{}"/*.go "{}"/go.mod' < ` + binDir + "/" + sageFileInputs + "; " +
+		"} 2>/dev/null | cksum"
 )
 
 func FromWorkDir(pathElems ...string) string {
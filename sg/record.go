@@ -0,0 +1,264 @@
+package sg
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+)
+
+// targetRun is the record of a single target run.
+type targetRun struct {
+	name     string
+	id       string
+	parent   *targetRun
+	lane     int
+	start    time.Time
+	end      time.Time
+	err      error
+	cached   bool
+	skipped  bool
+	commands []*commandRecord
+}
+
+// Statuses of target runs.
+const (
+	statusOK      = "ok"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+	statusCached  = "cached"
+	statusRunning = "running"
+)
+
+// status returns the status of the run. Runs that did not finish before the run failed are considered failed.
+func (r *targetRun) status(failed bool) string {
+	switch {
+	case r.skipped:
+		return statusSkipped
+	case r.err != nil:
+		return statusFailed
+	case r.end.IsZero() && failed:
+		return statusFailed
+	case r.end.IsZero():
+		return statusRunning
+	case r.cached:
+		return statusCached
+	default:
+		return statusOK
+	}
+}
+
+// commandRecord is the record of a command created by Command during a target run.
+//
+// The run of the command is observed through the copying of its stdout and stderr, which starts when the command is
+// started and ends when the command exits. The start and end are zero if the command was not run, or if both its stdout
+// and stderr were replaced.
+type commandRecord struct {
+	cmd     *exec.Cmd
+	args    []string
+	dir     string
+	created time.Time
+	start   time.Time
+	end     time.Time
+	copying int
+}
+
+// started records that copying the output of the command with args started.
+func (c *commandRecord) started(args []string) {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	if c.copying == 0 && c.start.IsZero() {
+		c.start = time.Now()
+		// The command may have been changed after it was created.
+		c.args = slices.Clone(args)
+		c.dir = c.cmd.Dir
+	}
+	c.copying++
+}
+
+// finished records that copying the output of the command finished.
+func (c *commandRecord) finished() {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	c.copying--
+	if c.copying == 0 {
+		c.end = time.Now()
+	}
+}
+
+// global state for the run recorder.
+//
+//nolint:gochecknoglobals
+var (
+	recordMu   sync.Mutex
+	recordRuns []*targetRun
+	recordT0   = time.Now()
+)
+
+type targetRunContextKey struct{}
+
+// startRecord records the start of a run of t and returns a context for the run.
+func startRecord(ctx context.Context, t Target) (context.Context, *targetRun) {
+	parent, _ := ctx.Value(targetRunContextKey{}).(*targetRun)
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	run := &targetRun{
+		name:   t.Name(),
+		id:     t.ID(),
+		parent: parent,
+		lane:   len(recordRuns) + 1,
+		start:  time.Now(),
+	}
+	recordRuns = append(recordRuns, run)
+	return context.WithValue(ctx, targetRunContextKey{}, run), run
+}
+
+// endRecord records the end of run.
+func endRecord(run *targetRun, err error) {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	run.end = time.Now()
+	run.err = err
+}
+
+// recordCommand records that cmd was created by the target run of ctx.
+// Returns nil if ctx has no target run.
+func recordCommand(ctx context.Context, cmd *exec.Cmd) *commandRecord {
+	run, ok := ctx.Value(targetRunContextKey{}).(*targetRun)
+	if !ok {
+		return nil
+	}
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	record := &commandRecord{cmd: cmd, args: slices.Clone(cmd.Args), dir: cmd.Dir, created: time.Now()}
+	run.commands = append(run.commands, record)
+	return record
+}
+
+// markCached records that the target run of ctx was skipped because its outputs were restored from the cache.
+func markCached(ctx context.Context) {
+	run, ok := ctx.Value(targetRunContextKey{}).(*targetRun)
+	if !ok {
+		return
+	}
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	run.cached = true
+}
+
+// markSkipped records that t was skipped, or cancelled while running, because one of its siblings failed.
+//
+// Runs are keyed by the ID of the target, which includes its args, and the run that depends on it. A target that is
+// skipped by one run and later run by another has a record for each.
+func markSkipped(ctx context.Context, t Target) {
+	parent, _ := ctx.Value(targetRunContextKey{}).(*targetRun)
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	for _, run := range recordRuns {
+		if run.id == t.ID() && run.parent == parent {
+			run.skipped = true
+			return
+		}
+	}
+	now := time.Now()
+	recordRuns = append(recordRuns, &targetRun{
+		name:    t.Name(),
+		id:      t.ID(),
+		parent:  parent,
+		lane:    len(recordRuns) + 1,
+		start:   now,
+		end:     now,
+		skipped: true,
+	})
+}
+
+// recordedRuns returns a snapshot of all recorded target runs, in start order.
+func recordedRuns() []targetRun {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	result := make([]targetRun, 0, len(recordRuns))
+	for _, run := range recordRuns {
+		snapshot := *run
+		snapshot.commands = make([]*commandRecord, 0, len(run.commands))
+		for _, command := range run.commands {
+			commandSnapshot := *command
+			snapshot.commands = append(snapshot.commands, &commandSnapshot)
+		}
+		result = append(result, snapshot)
+	}
+	return result
+}
+
+// runRecorded runs t and records the run.
+func runRecorded(ctx context.Context, t Target) (err error) {
+	ctx, run := startRecord(ctx, t)
+	defer func() {
+		if v := recover(); v != nil {
+			endRecord(run, panicError(v))
+			panic(v)
+		}
+		endRecord(run, err)
+	}()
+	return t.Run(ctx)
+}
+
+// RunMain runs f as the top-level target of a sagefile invocation and returns its error.
+// In watch mode, see WithWatch, RunMain instead runs the invocation each time files change, until ctx is cancelled.
+//
+// RunMain is called by the generated sagefile entrypoint, and should not be called from sagefiles.
+func RunMain(ctx context.Context, name string, f func(context.Context) error) error {
+	if isWatch(ctx) {
+		return watchMain(ctx)
+	}
+	ctx, output := withTargetOutput(ctx)
+	ctx = withTargetLoggers(ctx, name, nil)
+	defer func() {
+		// Write the output of the run before panicking, since the process exits without running other deferred
+		// functions.
+		if v := recover(); v != nil {
+			flushTargetOutput(name, output, panicError(v))
+			finish(true)
+			panic(v)
+		}
+	}()
+	err := runRecorded(ctx, fn{name: name, id: name, f: func(ctx context.Context) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				dependencyErr, ok := v.(*dependencyError)
+				if !ok {
+					panic(v)
+				}
+				err = dependencyErr
+			}
+		}()
+		return f(ctx)
+	}})
+	flushTargetOutput(name, output, err)
+	finish(err != nil)
+	return err
+}
+
+//nolint:gochecknoglobals
+var finishOnce sync.Once
+
+// finish writes the reports of the current run.
+func finish(failed bool) {
+	finishOnce.Do(func() {
+		flushFailedTargetOutputs()
+		runs := recordedRuns()
+		if err := writeTrace(runs); err != nil {
+			NewLogger("sage").Printf("failed to write trace: %v", err)
+		}
+		if err := writeSummary(os.Stderr, FromBuildDir(summaryFile), runs, failed); err != nil {
+			NewLogger("sage").Printf("failed to write summary: %v", err)
+		}
+	})
+}
+
+// exit finishes the current run and exits the process with code.
+func exit(code int) {
+	finish(code != 0)
+	os.Exit(code)
+}
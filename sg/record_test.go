@@ -0,0 +1,32 @@
+package sg
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTargetRun_status(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	for _, tt := range []struct {
+		name     string
+		run      targetRun
+		failed   bool
+		expected string
+	}{
+		{name: "ok", run: targetRun{start: now, end: now}, expected: statusOK},
+		{name: "failed", run: targetRun{start: now, end: now, err: errors.New("boom")}, expected: statusFailed},
+		{name: "cached", run: targetRun{start: now, end: now, cached: true}, expected: statusCached},
+		{name: "skipped", run: targetRun{start: now, end: now, err: errors.New("killed"), skipped: true}, expected: statusSkipped},
+		{name: "running", run: targetRun{start: now}, expected: statusRunning},
+		{name: "unfinished in failed run", run: targetRun{start: now}, failed: true, expected: statusFailed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.run.status(tt.failed); got != tt.expected {
+				t.Errorf("status() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
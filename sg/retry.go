@@ -0,0 +1,97 @@
+package sg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Timeout returns a Target that cancels the context passed to target after d.
+//
+// Commands created with Command are killed when the context is cancelled.
+func Timeout(target any, d time.Duration) Target {
+	return timeoutTarget{target: checkFunctions(target)[0], timeout: d}
+}
+
+type timeoutTarget struct {
+	target  Target
+	timeout time.Duration
+}
+
+// Name implements Target.
+func (t timeoutTarget) Name() string {
+	return t.target.Name()
+}
+
+// ID implements Target.
+func (t timeoutTarget) ID() string {
+	return t.target.ID()
+}
+
+// Run implements Target.
+func (t timeoutTarget) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	err := t.target.Run(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s: %w", t.timeout, err)
+	}
+	return err
+}
+
+// RetryConfig configures a retried target, see Retry.
+type RetryConfig struct {
+	// Retries is the maximum number of times to retry the target after the first attempt.
+	Retries int
+	// Backoff is the delay before the first retry. The delay is doubled for each following retry.
+	Backoff time.Duration
+	// Timeout is the maximum duration of each attempt. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// Retry returns a Target that runs target again when it fails, as configured by cfg.
+//
+// Each failed attempt is logged with the logger of the target.
+// Retrying stops when the context passed to the returned Target is cancelled.
+func Retry(target any, cfg RetryConfig) Target {
+	t := checkFunctions(target)[0]
+	if cfg.Timeout > 0 {
+		t = Timeout(t, cfg.Timeout)
+	}
+	return retryTarget{target: t, cfg: cfg}
+}
+
+type retryTarget struct {
+	target Target
+	cfg    RetryConfig
+}
+
+// Name implements Target.
+func (r retryTarget) Name() string {
+	return r.target.Name()
+}
+
+// ID implements Target.
+func (r retryTarget) ID() string {
+	return r.target.ID()
+}
+
+// Run implements Target.
+func (r retryTarget) Run(ctx context.Context) error {
+	attempts := r.cfg.Retries + 1
+	backoff := r.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		err := r.target.Run(ctx)
+		if err == nil || attempt == attempts || ctx.Err() != nil {
+			return err
+		}
+		Logger(ctx).Printf("attempt %d/%d failed, retrying in %s: %v", attempt, attempts, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,61 @@
+package sg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	t.Run("succeeds after retries", func(t *testing.T) {
+		t.Parallel()
+		var attempts int
+		target := Retry(func(context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("flaky")
+			}
+			return nil
+		}, RetryConfig{Retries: 2, Backoff: time.Millisecond})
+		if err := target.Run(context.Background()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if attempts != 3 {
+			t.Errorf("expected 3 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("gives up after retries", func(t *testing.T) {
+		t.Parallel()
+		var attempts int
+		target := Retry(func(context.Context) error {
+			attempts++
+			return errors.New("broken")
+		}, RetryConfig{Retries: 1, Backoff: time.Millisecond})
+		if err := target.Run(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+		if attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("times out each attempt", func(t *testing.T) {
+		t.Parallel()
+		var attempts int
+		target := Retry(func(ctx context.Context) error {
+			attempts++
+			<-ctx.Done()
+			return ctx.Err()
+		}, RetryConfig{Retries: 1, Timeout: time.Millisecond})
+		err := target.Run(context.Background())
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", attempts)
+		}
+	})
+}
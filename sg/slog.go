@@ -0,0 +1,136 @@
+package sg
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	targetLogKey  = "target"
+	chainLogKey   = "chain"
+	commandLogKey = "command"
+	prefixLogKey  = "prefix"
+)
+
+type slogLoggerContextKey struct{}
+
+// WithSlogLogger attaches a slog.Logger to the provided context.
+func WithSlogLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, slogLoggerContextKey{}, logger)
+}
+
+// SlogLogger returns the slog.Logger attached to ctx, or a logger writing to the log.Logger attached to ctx.
+//
+// The loggers attached by Deps have the attributes "target", with the name of the running target, and "chain",
+// with the names of the targets that depend on it. Output from commands created with Command also has the attribute
+// "command".
+//
+// By default, records are written as text in the same format as Logger. Set the environment variable
+// SAGE_LOG_FORMAT=json to write records, including the output of Logger, as JSON lines.
+func SlogLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(slogLoggerContextKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	if isJSONLogFormat() {
+		return slog.New(newLogHandler(os.Stderr, "sage", nil))
+	}
+	return slog.New(&textLogHandler{logger: Logger(ctx)})
+}
+
+// withTargetLoggers attaches the loggers of the target with the provided name and dependency chain to ctx.
+func withTargetLoggers(ctx context.Context, name string, chain []string) context.Context {
+	w := outputWriter(ctx, os.Stderr)
+	handler := newLogHandler(w, name, chain)
+	if isJSONLogFormat() {
+		ctx = WithLogger(ctx, slog.NewLogLogger(handler, slog.LevelInfo))
+	} else {
+		ctx = WithLogger(ctx, newLogger(w, name))
+	}
+	return WithSlogLogger(ctx, slog.New(handler))
+}
+
+func isJSONLogFormat() bool {
+	return strings.EqualFold(os.Getenv("SAGE_LOG_FORMAT"), "json")
+}
+
+// newLogHandler returns a handler writing to w for the logs of the target with the provided name and dependency chain.
+func newLogHandler(w io.Writer, name string, chain []string) slog.Handler {
+	attrs := []slog.Attr{slog.String(targetLogKey, loggerPrefix(name))}
+	if len(chain) > 0 {
+		attrs = append(attrs, slog.Any(chainLogKey, chain))
+	}
+	if isJSONLogFormat() {
+		return slog.NewJSONHandler(w, nil).WithAttrs(attrs)
+	}
+	return (&textLogHandler{logger: newLogger(w, name)}).WithAttrs(attrs)
+}
+
+// textLogHandler writes records to a log.Logger as the message followed by its attributes.
+// The target and chain attributes are left out, since they are already shown by the logger prefix.
+type textLogHandler struct {
+	logger *log.Logger
+	attrs  []slog.Attr
+	group  string
+}
+
+// Enabled implements slog.Handler.
+func (h *textLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+// Handle implements slog.Handler.
+func (h *textLogHandler) Handle(_ context.Context, record slog.Record) error {
+	var b strings.Builder
+	if record.Level != slog.LevelInfo {
+		b.WriteString(record.Level.String())
+		b.WriteByte(' ')
+	}
+	b.WriteString(record.Message)
+	writeAttr := func(attr slog.Attr) {
+		if attr.Key == targetLogKey || attr.Key == chainLogKey || attr.Key == commandLogKey {
+			return
+		}
+		b.WriteByte(' ')
+		b.WriteString(attr.String())
+	}
+	for _, attr := range h.attrs {
+		writeAttr(attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		writeAttr(attr)
+		return true
+	})
+	h.logger.Print(b.String())
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *textLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	result := *h
+	result.attrs = slices.Clip(result.attrs)
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		result.attrs = append(result.attrs, attr)
+	}
+	return &result
+}
+
+// WithGroup implements slog.Handler.
+func (h *textLogHandler) WithGroup(name string) slog.Handler {
+	result := *h
+	if result.group != "" {
+		name = result.group + "." + name
+	}
+	result.group = name
+	return &result
+}
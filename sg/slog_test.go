@@ -0,0 +1,77 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestTextLogHandler(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	handler := (&textLogHandler{logger: log.New(&out, "[build] ", 0)}).WithAttrs([]slog.Attr{
+		slog.String(targetLogKey, "build"),
+		slog.Any(chainLogKey, []string{"default"}),
+	})
+	logger := slog.New(handler).With("a", 1, commandLogKey, "go").WithGroup("g").With("b", 2)
+	logger.Info("message", "c", 3)
+	logger.Debug("debug")
+	logger.Warn("warning")
+	expected := "[build] message a=1 g.b=2 g.c=3\n[build] WARN warning a=1 g.b=2\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, nil))
+	if got := SlogLogger(WithSlogLogger(context.Background(), logger)); got != logger {
+		t.Errorf("expected the attached logger")
+	}
+	// A value of another type does not panic.
+	ctx := context.WithValue(context.Background(), slogLoggerContextKey{}, "not a logger")
+	if SlogLogger(ctx) == nil {
+		t.Errorf("expected a default logger")
+	}
+}
+
+func TestAppendLoggerPrefix_JSON(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	ctx := WithSlogLogger(context.Background(), slog.New(slog.NewJSONHandler(&out, nil)))
+	outer := AppendLoggerPrefix(ctx, "[outer] ")
+	nested := AppendLoggerPrefix(outer, "[nested] ")
+	sibling := AppendLoggerPrefix(outer, "[sibling] ")
+	replaced := AppendLoggerPrefix(WithSlogLogger(nested, slog.New(slog.NewJSONHandler(&out, nil))), "[new] ")
+	for _, ctx := range []context.Context{outer, nested, AppendLoggerPrefix(nested, "[deep] "), sibling, replaced} {
+		SlogLogger(ctx).Info("message")
+	}
+	var prefixes []string
+	for line := range strings.Lines(out.String()) {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Count(line, `"`+prefixLogKey+`"`) != 1 {
+			t.Errorf("expected a single prefix attribute, got %s", line)
+		}
+		prefix, _ := record[prefixLogKey].(string)
+		prefixes = append(prefixes, prefix)
+	}
+	expected := []string{
+		"[outer]",
+		"[outer] [nested]",
+		"[outer] [nested] [deep]",
+		"[outer] [sibling]",
+		"[new]",
+	}
+	if strings.Join(prefixes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected prefixes %q, got %q", expected, prefixes)
+	}
+}
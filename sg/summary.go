@@ -0,0 +1,95 @@
+package sg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const summaryFile = "summary.json"
+
+// targetSummary is the summary of a target run in the JSON summary file.
+type targetSummary struct {
+	Name       string    `json:"name"`
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	Start      time.Time `json:"start"`
+	DurationMS int64     `json:"durationMs"`
+	LogPrefix  string    `json:"logPrefix"`
+	Chain      []string  `json:"chain"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// writeSummary writes a summary of the target runs to path, and prints it to w as a table when more than one
+// target has run. Printing can be disabled with SAGE_SUMMARY=false.
+func writeSummary(w io.Writer, path string, runs []targetRun, failed bool) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	summaries := make([]targetSummary, 0, len(runs))
+	for _, run := range runs {
+		end := run.end
+		if end.IsZero() {
+			end = time.Now()
+		}
+		summary := targetSummary{
+			Name:       loggerPrefix(run.name),
+			ID:         run.id,
+			Status:     run.status(failed),
+			Start:      run.start,
+			DurationMS: end.Sub(run.start).Milliseconds(),
+			LogPrefix:  fmt.Sprintf("[%s]", loggerPrefix(run.name)),
+			Chain:      run.chain(),
+		}
+		if run.err != nil {
+			summary.Error = run.err.Error()
+		}
+		summaries = append(summaries, summary)
+	}
+	data, err := json.MarshalIndent(map[string]any{"targets": summaries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	if value, ok := os.LookupEnv("SAGE_SUMMARY"); (ok && !isTrue(value)) || len(runs) < 2 {
+		return nil
+	}
+	printSummary(w, summaries)
+	return nil
+}
+
+// printSummary prints the summaries to w as a table, or as one record per target when logging JSON.
+func printSummary(w io.Writer, summaries []targetSummary) {
+	if isJSONLogFormat() {
+		logger := slog.New(newLogHandler(w, "sage", nil))
+		for _, summary := range summaries {
+			logger.Info(
+				"summary",
+				"name", summary.Name,
+				"status", summary.Status,
+				"durationMs", summary.DurationMS,
+			)
+		}
+		return
+	}
+	var table bytes.Buffer
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STATUS\tDURATION\tTARGET")
+	for _, summary := range summaries {
+		duration := (time.Duration(summary.DurationMS) * time.Millisecond).String()
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", summary.Status, duration, summary.LogPrefix)
+	}
+	_ = tw.Flush()
+	logger := newLogger(w, "sage")
+	logger.Println("summary:")
+	for line := range bytes.Lines(table.Bytes()) {
+		logger.Print(string(bytes.TrimRight(line, "\n")))
+	}
+}
@@ -0,0 +1,109 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWriteSummary(t *testing.T) {
+	t.Setenv("SAGE_SUMMARY", "true")
+	t.Setenv("SAGE_LOG_FORMAT", "text")
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	build := &targetRun{name: "main.Build", id: "main.Build", lane: 1, start: t0, end: t0.Add(2 * time.Second)}
+	runs := []targetRun{
+		*build,
+		{
+			name:   "main.Test",
+			id:     `main.Test(["./a"])`,
+			parent: build,
+			lane:   2,
+			start:  t0,
+			end:    t0.Add(1500 * time.Millisecond),
+			err:    errors.New("test failed"),
+		},
+		{name: "main.Test", id: `main.Test(["./b"])`, parent: build, lane: 3, start: t0, end: t0, skipped: true},
+		{name: "main.Lint", id: "main.Lint", parent: build, lane: 4, start: t0, end: t0.Add(time.Second), cached: true},
+	}
+	var out bytes.Buffer
+	path := filepath.Join(t.TempDir(), summaryFile)
+	if err := writeSummary(&out, path, runs, true); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary struct {
+		Targets []targetSummary `json:"targets"`
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	expected := []targetSummary{
+		{Name: "build", ID: "main.Build", Status: statusOK, Start: t0, DurationMS: 2000, LogPrefix: "[build]", Chain: []string{"build"}},
+		{
+			Name:       "test",
+			ID:         `main.Test(["./a"])`,
+			Status:     statusFailed,
+			Start:      t0,
+			DurationMS: 1500,
+			LogPrefix:  "[test]",
+			Chain:      []string{"build", "test"},
+			Error:      "test failed",
+		},
+		{Name: "test", ID: `main.Test(["./b"])`, Status: statusSkipped, Start: t0, LogPrefix: "[test]", Chain: []string{"build", "test"}},
+		{Name: "lint", ID: "main.Lint", Status: statusCached, Start: t0, DurationMS: 1000, LogPrefix: "[lint]", Chain: []string{"build", "lint"}},
+	}
+	if len(summary.Targets) != len(runs) {
+		t.Fatalf("expected %d targets in summary, got %d", len(runs), len(summary.Targets))
+	}
+	for i, expected := range expected {
+		actual := summary.Targets[i]
+		if actual.Name != expected.Name || actual.ID != expected.ID || actual.Status != expected.Status ||
+			!actual.Start.Equal(expected.Start) || actual.DurationMS != expected.DurationMS ||
+			actual.LogPrefix != expected.LogPrefix || !slices.Equal(actual.Chain, expected.Chain) ||
+			actual.Error != expected.Error {
+			t.Errorf("target %d: expected %+v, got %+v", i, expected, actual)
+		}
+	}
+	const expectedTable = `[sage] summary:
+[sage] STATUS   DURATION  TARGET
+[sage] ok       2s        [build]
+[sage] failed   1.5s      [test]
+[sage] skipped  0s        [test]
+[sage] cached   1s        [lint]
+`
+	if out.String() != expectedTable {
+		t.Errorf("expected summary table:\n%s\ngot:\n%s", expectedTable, out.String())
+	}
+}
+
+func TestMarkSkipped(t *testing.T) {
+	t.Parallel()
+	ctxA, _ := startRecord(context.Background(), fn{name: "main.SkippedTestA", id: "main.SkippedTestA"})
+	ctxB, _ := startRecord(context.Background(), fn{name: "main.SkippedTestB", id: "main.SkippedTestB"})
+	target := fn{name: "main.SkippedTestTarget", id: `main.SkippedTestTarget(["x"])`}
+	// Skipped by A before it ran.
+	markSkipped(ctxA, target)
+	// Run by B, and cancelled.
+	_, run := startRecord(ctxB, target)
+	endRecord(run, context.Canceled)
+	markSkipped(ctxB, target)
+	var skipped []string
+	for _, run := range recordedRuns() {
+		if run.id == target.ID() && run.skipped {
+			skipped = append(skipped, run.parent.name)
+		}
+	}
+	slices.Sort(skipped)
+	if expected := []string{"main.SkippedTestA", "main.SkippedTestB"}; !slices.Equal(skipped, expected) {
+		t.Errorf("expected target to be skipped by %v, got %v", expected, skipped)
+	}
+}
@@ -0,0 +1,125 @@
+package sg
+
+import (
+	"go/doc"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+// generateTaskfile generates a Taskfile.yml with the same targets as the Makefile mk.
+// The Taskfile of the root Makefile includes the Taskfiles of the namespace Makefiles.
+func generateTaskfile(g *codegen.File, pkg *sagePackage, mk Makefile, mks ...Makefile) error {
+	includePath, err := filepath.Rel(filepath.Dir(mk.Taskfile), FromSageDir())
+	if err != nil {
+		return err
+	}
+	g.P("# To learn more, see ", includePath, "/main.go and https://github.com/einride/sage.")
+	g.P()
+	g.P("version: '3'")
+	g.P()
+	g.P("vars:")
+	g.P("  SAGE_DIR: ", yamlString("{{.TASKFILE_DIR}}/"+includePath))
+	g.P("  SAGEFILE: ", yamlString("{{.SAGE_DIR}}/"+filepath.Join(binDir, sageFileBinary)))
+	vars := namespaceVars(reflect.ValueOf(mk.Namespace))
+	for _, v := range vars {
+		// Global variables take precedence over the environment, so the environment is used as their default.
+		g.P("  ", v.name, ": ", yamlString("{{."+v.name+" | default "+strconv.Quote(formatNamespaceValue(v.value))+"}}"))
+	}
+	g.P()
+	g.P("env:")
+	g.P("  GOWORK: ", yamlString(`{{.GOWORK | default "off"}}`))
+	if mk.namespaceName() == "" {
+		var includes []Makefile
+		for _, i := range mks {
+			if i.namespaceName() != "" && i.Taskfile != "" {
+				includes = append(includes, i)
+			}
+		}
+		if len(includes) > 0 {
+			g.P()
+			g.P("includes:")
+		}
+		for _, i := range includes {
+			taskfilePath, err := filepath.Rel(filepath.Dir(mk.Taskfile), i.Taskfile)
+			if err != nil {
+				return err
+			}
+			g.P("  ", toMakeTarget(i.namespaceName()), ":")
+			g.P("    taskfile: ", yamlString("./"+taskfilePath))
+			g.P("    dir: ", yamlString("./"+filepath.Dir(taskfilePath)))
+		}
+	}
+	g.P()
+	g.P("tasks:")
+	g.P("  sagefile:")
+	g.P("    internal: true")
+	g.P("    run: once")
+	g.P("    dir: '{{.SAGE_DIR}}'")
+	// The sagefile is rebuilt when the hash of the files it is built from differs from its stamp.
+	sageStamp := filepath.Join(binDir, sageFileStamp)
+	g.P("    status:")
+	g.P("      - ", yamlString(`test -x "{{.SAGEFILE}}"`))
+	g.P("      - ", yamlString(`test "$(`+sageFileHashCommand+`)" = "$(cat `+sageStamp+` 2>/dev/null)"`))
+	g.P("    cmds:")
+	g.P("      - go run .")
+	g.P("      - ", yamlString(sageFileHashCommand+" > "+sageStamp))
+	var hasDefaultTask bool
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if function.Recv != mk.namespaceName() || isHiddenTarget(function) {
+			return
+		}
+		name := effectiveMakeTarget(function)
+		hasDefaultTask = hasDefaultTask || name == "default"
+		g.P()
+		g.P("  ", name, ":")
+		if synopsis := pkg.Synopsis(function.Doc); synopsis != "" {
+			g.P("    desc: ", yamlString(synopsis))
+		}
+		args := toMakeVars(function.Decl.Type.Params.List[1:])
+		defaults := getTargetDefaults(function)
+		var required []string
+		for _, arg := range args {
+			if _, ok := defaults[arg]; !ok {
+				required = append(required, arg)
+			}
+		}
+		if len(required) > 0 {
+			g.P("    requires:")
+			g.P("      vars: [", strings.Join(required, ", "), "]")
+		}
+		g.P("    deps: [sagefile]")
+		g.P("    cmds:")
+		cmd := `"{{.SAGEFILE}}" ` + getTargetFunctionName(function)
+		for _, v := range vars {
+			cmd += ` "` + v.name + `={{.` + v.name + `}}"`
+		}
+		for _, arg := range args {
+			if value, ok := defaults[arg]; ok {
+				cmd += ` "{{.` + arg + ` | default ` + strconv.Quote(value) + `}}"`
+			} else {
+				cmd += ` "{{.` + arg + `}}"`
+			}
+		}
+		g.P("      - ", yamlString(cmd))
+	})
+	if defaultName := mk.defaultTargetName(); defaultName != "" && !hasDefaultTask {
+		defaultTarget := toMakeTarget(defaultName)
+		if f := findDocFunc(pkg, defaultName); f != nil {
+			defaultTarget = effectiveMakeTarget(f)
+		}
+		g.P()
+		g.P("  default:")
+		g.P("    cmds:")
+		g.P("      - task: ", defaultTarget)
+	}
+	return nil
+}
+
+// yamlString returns s as a single-quoted YAML string.
+func yamlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
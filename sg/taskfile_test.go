@@ -0,0 +1,41 @@
+package sg
+
+import (
+	"strings"
+	"testing"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+func TestGenerateTaskfile(t *testing.T) {
+	t.Parallel()
+	pkg := parseTestSagePackage(t, helpTestSagefile)
+	mks := []Makefile{
+		{Taskfile: FromGitRoot("Taskfile.yml")},
+		{Taskfile: FromGitRoot("proto", "Taskfile.yml"), Namespace: Proto{}},
+	}
+	g := codegen.NewMakefile(codegen.FileConfig{})
+	if err := generateTaskfile(g, pkg, mks[0], mks...); err != nil {
+		t.Fatal(err)
+	}
+	got := string(g.RawContent())
+	for _, expected := range []string{
+		"  SAGE_DIR: '{{.TASKFILE_DIR}}/.sage'\n",
+		"includes:\n  proto:\n    taskfile: './proto/Taskfile.yml'\n    dir: './proto'\n",
+		`  deploy:
+    desc: 'Deploy deploys the service to env.'
+    requires:
+      vars: [service]
+    deps: [sagefile]
+    cmds:
+      - '"{{.SAGEFILE}}" Deploy "{{.service}}" "{{.env | default "dev"}}"'
+`,
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected Taskfile to contain\n%s\nbut got\n%s", expected, got)
+		}
+	}
+	if strings.Contains(got, "helper") {
+		t.Errorf("expected Taskfile to not contain hidden target helper, got\n%s", got)
+	}
+}
@@ -0,0 +1,160 @@
+package sg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const traceFile = "trace.json"
+
+// traceEvent is an event in the Chrome Trace Event Format.
+//
+// See: https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type traceEvent struct {
+	Name      string         `json:"name"`
+	Category  string         `json:"cat,omitempty"`
+	Phase     string         `json:"ph"`
+	Timestamp int64          `json:"ts"`
+	Duration  int64          `json:"dur,omitempty"`
+	PID       int            `json:"pid"`
+	TID       int            `json:"tid"`
+	ID        int            `json:"id,omitempty"`
+	Scope     string         `json:"s,omitempty"`
+	BindPoint string         `json:"bp,omitempty"`
+	Args      map[string]any `json:"args,omitempty"`
+}
+
+// writeTrace writes the recorded target runs to the build dir, if tracing is enabled with SAGE_TRACE.
+func writeTrace(runs []targetRun) error {
+	if value, ok := os.LookupEnv("SAGE_TRACE"); !ok || !isTrue(value) {
+		return nil
+	}
+	data, err := json.MarshalIndent(map[string]any{
+		"traceEvents":     traceEvents(runs),
+		"displayTimeUnit": "ms",
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := FromBuildDir(traceFile)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	relPath, err := filepath.Rel(FromGitRoot(), path)
+	if err != nil {
+		return err
+	}
+	NewLogger("sage").Printf("wrote trace to %s, open it with https://ui.perfetto.dev", relPath)
+	return nil
+}
+
+// traceEvents converts target runs to trace events.
+// Each run gets its own lane, with flow events from the depending target and spans for the commands it ran.
+func traceEvents(runs []targetRun) []traceEvent {
+	events := make([]traceEvent, 0, len(runs)*3)
+	for _, run := range runs {
+		end := run.end
+		if end.IsZero() {
+			end = time.Now()
+		}
+		events = append(events, traceEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			PID:   1,
+			TID:   run.lane,
+			Args:  map[string]any{"name": loggerPrefix(run.name)},
+		})
+		args := map[string]any{
+			"id":    run.id,
+			"chain": strings.Join(run.chain(), " -> "),
+		}
+		if run.err != nil {
+			args["error"] = run.err.Error()
+		}
+		if run.cached {
+			args["cached"] = true
+		}
+		if run.skipped {
+			args["skipped"] = true
+		}
+		events = append(events, traceEvent{
+			Name:      loggerPrefix(run.name),
+			Category:  "target",
+			Phase:     "X",
+			Timestamp: traceTimestamp(run.start),
+			Duration:  max(end.Sub(run.start).Microseconds(), 1),
+			PID:       1,
+			TID:       run.lane,
+			Args:      args,
+		})
+		if run.parent != nil {
+			events = append(
+				events,
+				traceEvent{
+					Name:      "dependency",
+					Category:  "dependency",
+					Phase:     "s",
+					Timestamp: traceTimestamp(run.start),
+					PID:       1,
+					TID:       run.parent.lane,
+					ID:        run.lane,
+				},
+				traceEvent{
+					Name:      "dependency",
+					Category:  "dependency",
+					Phase:     "f",
+					BindPoint: "e",
+					Timestamp: traceTimestamp(run.start),
+					PID:       1,
+					TID:       run.lane,
+					ID:        run.lane,
+				},
+			)
+		}
+		for _, command := range run.commands {
+			event := traceEvent{
+				Name:     filepath.Base(command.args[0]),
+				Category: "command",
+				PID:      1,
+				TID:      run.lane,
+				Args: map[string]any{
+					"args": strings.Join(command.args, " "),
+					"dir":  command.dir,
+				},
+			}
+			switch {
+			case command.start.IsZero():
+				// The run of the command was not observed, so only its creation is shown.
+				event.Phase = "i"
+				event.Scope = "t"
+				event.Timestamp = traceTimestamp(command.created)
+			default:
+				commandEnd := command.end
+				if commandEnd.IsZero() {
+					commandEnd = end
+				}
+				event.Phase = "X"
+				event.Timestamp = traceTimestamp(command.start)
+				event.Duration = max(commandEnd.Sub(command.start).Microseconds(), 1)
+			}
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func traceTimestamp(t time.Time) int64 {
+	return t.Sub(recordT0).Microseconds()
+}
+
+// chain returns the names of the targets that lead to run, starting with the top-level target.
+func (r *targetRun) chain() []string {
+	var result []string
+	for run := r; run != nil; run = run.parent {
+		result = append([]string{loggerPrefix(run.name)}, result...)
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package sg
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTraceEvents(t *testing.T) {
+	t.Parallel()
+	ctx, run := startRecord(context.Background(), fn{name: "main.Build", id: "main.Build"})
+	if err := Command(ctx, "sh", "-c", "sleep 0.1").Run(); err != nil {
+		t.Fatal(err)
+	}
+	_ = Command(ctx, "sh", "-c", "exit 0")
+	endRecord(run, nil)
+	var runs []targetRun
+	for _, r := range recordedRuns() {
+		if r.lane == run.lane {
+			runs = append(runs, r)
+		}
+	}
+	data, err := json.Marshal(map[string]any{"traceEvents": traceEvents(runs)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []struct {
+			Name      string         `json:"name"`
+			Category  string         `json:"cat"`
+			Phase     string         `json:"ph"`
+			Timestamp int64          `json:"ts"`
+			Duration  int64          `json:"dur"`
+			TID       int            `json:"tid"`
+			Args      map[string]any `json:"args"`
+		} `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatal(err)
+	}
+	var target, command, notRun bool
+	for _, event := range trace.TraceEvents {
+		if event.TID != run.lane {
+			t.Errorf("expected event %q in lane %d, got %d", event.Name, run.lane, event.TID)
+		}
+		switch {
+		case event.Category == "target":
+			target = true
+			if event.Phase != "X" || event.Name != "build" {
+				t.Errorf("unexpected target event: %+v", event)
+			}
+		case event.Category == "command" && event.Args["args"] == "sh -c sleep 0.1":
+			command = true
+			if event.Phase != "X" {
+				t.Errorf("expected command span, got phase %q", event.Phase)
+			}
+			// The copying of the output of the command may start a bit after the command is started.
+			if event.Duration < (50 * time.Millisecond).Microseconds() {
+				t.Errorf("expected command span to cover the run of the command, got duration %dµs", event.Duration)
+			}
+			if event.Timestamp < traceTimestamp(run.start) ||
+				event.Timestamp+event.Duration > traceTimestamp(run.end)+1 {
+				t.Errorf("expected command span within the target span, got %+v", event)
+			}
+		case event.Category == "command" && event.Args["args"] == "sh -c exit 0":
+			notRun = true
+			if event.Phase != "i" {
+				t.Errorf("expected instant event for command that was not run, got phase %q", event.Phase)
+			}
+		}
+	}
+	if !target || !command || !notRun {
+		t.Errorf("missing events in trace: %s", data)
+	}
+}
@@ -0,0 +1,79 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"go.einride.tech/sage/sg/internal/watcher"
+)
+
+type watchContextKey struct{}
+
+// WithWatch returns a context where RunMain runs the top-level target in watch mode.
+//
+// In watch mode, the target is run again in a new process, with fresh state, each time files in the git working tree
+// that are not ignored by git change. Watch mode can also be enabled by setting the environment variable
+// SAGE_WATCH=true, and from the generated Makefiles with "make watch TARGET=<target>" or "make watch-<target>".
+func WithWatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, watchContextKey{}, true)
+}
+
+func isWatch(ctx context.Context) bool {
+	if watch, ok := ctx.Value(watchContextKey{}).(bool); ok {
+		return watch
+	}
+	value, ok := os.LookupEnv("SAGE_WATCH")
+	return ok && isTrue(value)
+}
+
+// watchMain runs the current sagefile invocation, without watch mode, each time the git working tree changes,
+// until ctx is cancelled.
+func watchMain(ctx context.Context) error {
+	logger := Logger(ctx)
+	w, err := watcher.New(FromGitRoot())
+	if err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+	defer w.Close()
+	if w.Polling() {
+		logger.Println("file system events are not available, polling for changes")
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "watch" {
+		args = args[1:]
+	}
+	for {
+		cmd := exec.CommandContext(ctx, executable, args...)
+		cmd.Env = append(os.Environ(), "SAGE_WATCH=false")
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil && ctx.Err() == nil {
+			logger.Printf("failed: %v", err)
+		}
+		logger.Println("watching for changes, press Ctrl+C to stop...")
+		changed, err := w.Wait(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("watch: %w", err)
+		}
+		logger.Printf("changed: %s", summarizeFiles(changed))
+	}
+}
+
+func summarizeFiles(files []string) string {
+	const maxFiles = 3
+	if len(files) <= maxFiles {
+		return strings.Join(files, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(files[:maxFiles], ", "), len(files)-maxFiles)
+}
@@ -33,6 +33,10 @@ func GoInstall(ctx context.Context, pkg, version string) (string, error) {
 		return symlink, nil
 	}
 	pkgVersion := fmt.Sprintf("%s@%s", pkg, version)
+	if sg.IsDryRun(ctx) {
+		sg.Logger(ctx).Printf("dry run: would build %s", pkgVersion)
+		return filepath.Join(sg.FromBinDir(), filepath.Base(executable)), nil
+	}
 	sg.Logger(ctx).Printf("building %s...", pkgVersion)
 	cmd := sg.Command(ctx, "go", "install", pkgVersion)
 	cmd.Env = append(cmd.Env, "GOBIN="+filepath.Dir(executable))
@@ -60,6 +64,10 @@ func GoInstallWithGoVersion(ctx context.Context, pkg, version string) (string, e
 		return symlink, nil
 	}
 	pkgVersion := fmt.Sprintf("%s@%s", pkg, version)
+	if sg.IsDryRun(ctx) {
+		sg.Logger(ctx).Printf("dry run: would build %s", pkgVersion)
+		return filepath.Join(sg.FromBinDir(), filepath.Base(executable)), nil
+	}
 	sg.Logger(ctx).Printf("building %s...", pkgVersion)
 	cmd := sg.Command(ctx, "go", "install", pkgVersion)
 	cmd.Env = append(cmd.Env, "GOBIN="+filepath.Dir(executable))
@@ -76,6 +84,10 @@ func GoInstallWithGoVersion(ctx context.Context, pkg, version string) (string, e
 // GoInstallWithModfile builds and installs a go binary given the package and a path
 // to the local go.mod file.
 func GoInstallWithModfile(ctx context.Context, pkg, file string) (string, error) {
+	if sg.IsDryRun(ctx) {
+		sg.Logger(ctx).Printf("dry run: would build %s with the version in %s", pkg, file)
+		return sg.FromBinDir(filepath.Base(trimVersionSuffix(pkg))), nil
+	}
 	cmd := sg.Command(ctx, "go", "list", "-f", "{{.Module.Version}}", pkg)
 	cmd.Dir = filepath.Dir(file)
 	var b bytes.Buffer
@@ -58,7 +58,7 @@ func newFileState() *fileState {
 
 // FromLocal can be used to work with local archive files.
 // HTTP related Options, such as WithHTTPHeader don't do anything here.
-func FromLocal(_ context.Context, filepath string, opts ...Opt) error {
+func FromLocal(ctx context.Context, filepath string, opts ...Opt) error {
 	s := newFileState()
 	for _, o := range opts {
 		o(s)
@@ -75,6 +75,10 @@ func FromLocal(_ context.Context, filepath string, opts ...Opt) error {
 		}
 	}
 
+	if sg.IsDryRun(ctx) {
+		sg.Logger(ctx).Printf("dry run: would extract %s", filepath)
+		return nil
+	}
 	f, err := os.Open(filepath)
 	if err != nil {
 		return fmt.Errorf("unable to open local file: %w", err)
@@ -99,6 +103,10 @@ func FromRemote(ctx context.Context, addr string, opts ...Opt) error {
 			return nil
 		}
 	}
+	if sg.IsDryRun(ctx) {
+		sg.Logger(ctx).Printf("dry run: would fetch %s", addr)
+		return nil
+	}
 	sg.Logger(ctx).Printf("fetching %s ...", addr)
 	rStream, cleanup, err := s.downloadBinary(ctx, addr)
 	if err != nil {
@@ -8,7 +8,6 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
-	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -52,27 +51,22 @@ func CommandInDirectory(ctx context.Context, directory string, args ...string) *
 
 // Run GolangCI-Lint in every Go module from the root of the current git repo.
 func Run(ctx context.Context, args ...string) error {
-	var commands []*exec.Cmd
-	if err := filepath.WalkDir(sg.FromGitRoot(), func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() || d.Name() != "go.mod" {
-			return nil
-		}
-		fileInfo, err := d.Info()
-		if err != nil {
+	return RunInModules(ctx, sg.GoModulesConfig{}, args...)
+}
+
+// RunInModules runs GolangCI-Lint in the Go modules listed by [sg.GoModules] with config.
+func RunInModules(ctx context.Context, config sg.GoModulesConfig, args ...string) error {
+	modules, err := sg.GoModules(ctx, config)
+	if err != nil {
+		return err
+	}
+	commands := make([]*exec.Cmd, 0, len(modules))
+	for _, module := range modules {
+		cmd := CommandInDirectory(ctx, module, args...)
+		commands = append(commands, cmd)
+		if err := cmd.Start(); err != nil {
 			return err
 		}
-		// ignore if it's an empty go.mod
-		if fileInfo.Size() == 0 {
-			return nil
-		}
-		cmd := CommandInDirectory(ctx, filepath.Dir(path), args...)
-		commands = append(commands, cmd)
-		return cmd.Start()
-	}); err != nil {
-		return err
 	}
 	errs := make([]error, 0, len(commands))
 	for _, cmd := range commands {
@@ -83,22 +77,25 @@ func Run(ctx context.Context, args ...string) error {
 
 // Run GolangCI-Lint --fix in every Go module from the root of the current git repo.
 func Fix(ctx context.Context, args ...string) error {
-	var commands []*exec.Cmd
-	if err := filepath.WalkDir(sg.FromGitRoot(), func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() || d.Name() != "go.mod" {
-			return nil
-		}
+	return FixInModules(ctx, sg.GoModulesConfig{}, args...)
+}
+
+// FixInModules runs GolangCI-Lint --fix in the Go modules listed by [sg.GoModules] with config.
+func FixInModules(ctx context.Context, config sg.GoModulesConfig, args ...string) error {
+	modules, err := sg.GoModules(ctx, config)
+	if err != nil {
+		return err
+	}
+	commands := make([]*exec.Cmd, 0, len(modules))
+	for _, module := range modules {
 		cmd := Command(
 			ctx,
 			append([]string{"run", "--allow-serial-runners", "-c", defaultConfigPath(), "--fix"}, args...)...)
-		cmd.Dir = filepath.Dir(path)
+		cmd.Dir = module
 		commands = append(commands, cmd)
-		return cmd.Start()
-	}); err != nil {
-		return err
+		if err := cmd.Start(); err != nil {
+			return err
+		}
 	}
 	for _, cmd := range commands {
 		if err := cmd.Wait(); err != nil {
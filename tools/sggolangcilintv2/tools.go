@@ -7,7 +7,6 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
-	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -51,6 +50,9 @@ type Config struct {
 	// - allow you to optionally use regexps here, like ".*\\.my\\.go$".
 	// - treat paths relative to the setting of RunRelativePathMode.
 	FormattersExclusionsPaths []string
+	// Which Go modules not to run in, as glob patterns of module directories relative to the git root.
+	// See [sg.GoModulesConfig] for the syntax of the patterns.
+	ExcludeModules []string
 }
 
 func Command(ctx context.Context, config Config, args ...string) *exec.Cmd {
@@ -88,27 +90,17 @@ func CommandFmtInDirectory(ctx context.Context, config Config, directory string,
 
 // Run GolangCI-Lint in every Go module from the root of the current git repo.
 func Run(ctx context.Context, config Config, args ...string) error {
-	var commands []*exec.Cmd
-	if err := filepath.WalkDir(sg.FromGitRoot(), func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() || d.Name() != "go.mod" {
-			return nil
-		}
-		fileInfo, err := d.Info()
-		if err != nil {
+	modules, err := sg.GoModules(ctx, sg.GoModulesConfig{Exclude: config.ExcludeModules})
+	if err != nil {
+		return err
+	}
+	commands := make([]*exec.Cmd, 0, len(modules))
+	for _, module := range modules {
+		cmd := CommandRunInDirectory(ctx, config, module, args...)
+		commands = append(commands, cmd)
+		if err := cmd.Start(); err != nil {
 			return err
 		}
-		// ignore if it's an empty go.mod
-		if fileInfo.Size() == 0 {
-			return filepath.SkipAll
-		}
-		cmd := CommandRunInDirectory(ctx, config, filepath.Dir(path), args...)
-		commands = append(commands, cmd)
-		return cmd.Start()
-	}); err != nil {
-		return err
 	}
 	errs := make([]error, 0, len(commands))
 	for _, cmd := range commands {
@@ -119,32 +111,22 @@ func Run(ctx context.Context, config Config, args ...string) error {
 
 // Run GolangCI-Lint --fix in every Go module from the root of the current git repo.
 func Fix(ctx context.Context, config Config, args ...string) error {
-	var commands []*exec.Cmd
-	if err := filepath.WalkDir(sg.FromGitRoot(), func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() || d.Name() != "go.mod" {
-			return nil
-		}
-		fileInfo, err := d.Info()
-		if err != nil {
-			return err
-		}
-		// ignore if it's an empty go.mod
-		if fileInfo.Size() == 0 {
-			return nil
-		}
+	modules, err := sg.GoModules(ctx, sg.GoModulesConfig{Exclude: config.ExcludeModules})
+	if err != nil {
+		return err
+	}
+	commands := make([]*exec.Cmd, 0, len(modules))
+	for _, module := range modules {
 		cmd := Command(
 			ctx,
 			config,
 			append([]string{"run", "--allow-serial-runners", "-c", defaultConfigPath(), "--fix"}, args...)...,
 		)
-		cmd.Dir = filepath.Dir(path)
+		cmd.Dir = module
 		commands = append(commands, cmd)
-		return cmd.Start()
-	}); err != nil {
-		return err
+		if err := cmd.Start(); err != nil {
+			return err
+		}
 	}
 	for _, cmd := range commands {
 		if err := cmd.Wait(); err != nil {
@@ -157,20 +139,18 @@ func Fix(ctx context.Context, config Config, args ...string) error {
 // Run `golangci-lint fmt` in every Go module from the root of the current git repo.
 // This writes the formatting to the files. Add the `--diff` argument if you don't want it to write to the files.
 func Fmt(ctx context.Context, config Config, args ...string) error {
-	var commands []*exec.Cmd
-	if err := filepath.WalkDir(sg.FromGitRoot(), func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() || d.Name() != "go.mod" {
-			return nil
-		}
+	modules, err := sg.GoModules(ctx, sg.GoModulesConfig{Exclude: config.ExcludeModules})
+	if err != nil {
+		return err
+	}
+	commands := make([]*exec.Cmd, 0, len(modules))
+	for _, module := range modules {
 		cmd := Command(ctx, config, append([]string{"fmt", "-c", defaultConfigPath()}, args...)...)
-		cmd.Dir = filepath.Dir(path)
+		cmd.Dir = module
 		commands = append(commands, cmd)
-		return cmd.Start()
-	}); err != nil {
-		return err
+		if err := cmd.Start(); err != nil {
+			return err
+		}
 	}
 	for _, cmd := range commands {
 		if err := cmd.Wait(); err != nil {
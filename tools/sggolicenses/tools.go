@@ -14,9 +14,7 @@ package sggolicenses
 
 import (
 	"context"
-	"io/fs"
 	"os/exec"
-	"path/filepath"
 	"strings"
 
 	"go.einride.tech/sage/sg"
@@ -58,17 +56,14 @@ func CheckDir(ctx context.Context, directory string, disallowedTypes ...string)
 // Check for disallowed types of Go licenses.
 // By default, Google's forbidden and restricted types are disallowed.
 func Check(ctx context.Context, disallowedTypes ...string) error {
-	var goModPaths []string
-	if err := filepath.WalkDir(sg.FromGitRoot(), func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() || d.Name() != "go.mod" {
-			return nil
-		}
-		goModPaths = append(goModPaths, filepath.Dir(path))
-		return nil
-	}); err != nil {
+	return CheckInModules(ctx, sg.GoModulesConfig{}, disallowedTypes...)
+}
+
+// CheckInModules checks for disallowed types of Go licenses like [Check], in the Go modules listed by [sg.GoModules]
+// with config.
+func CheckInModules(ctx context.Context, config sg.GoModulesConfig, disallowedTypes ...string) error {
+	goModPaths, err := sg.GoModules(ctx, config)
+	if err != nil {
 		return err
 	}
 
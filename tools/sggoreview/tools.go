@@ -3,7 +3,6 @@ package sggoreview
 import (
 	"context"
 	"fmt"
-	"io/fs"
 	"os/exec"
 	"path/filepath"
 	"runtime"
@@ -30,20 +29,25 @@ func Command(ctx context.Context, args ...string) *exec.Cmd {
 //
 // Deprecated: Use sggolangcilint.Run for all your linting needs.
 func Run(ctx context.Context, args ...string) error {
-	var commands []*exec.Cmd
-	if err := filepath.WalkDir(sg.FromGitRoot(), func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() || d.Name() != "go.mod" {
-			return nil
-		}
+	return RunInModules(ctx, sg.GoModulesConfig{}, args...)
+}
+
+// RunInModules runs goreview in the Go modules listed by [sg.GoModules] with config.
+//
+// Deprecated: Use sggolangcilint.RunInModules for all your linting needs.
+func RunInModules(ctx context.Context, config sg.GoModulesConfig, args ...string) error {
+	modules, err := sg.GoModules(ctx, config)
+	if err != nil {
+		return err
+	}
+	commands := make([]*exec.Cmd, 0, len(modules))
+	for _, module := range modules {
 		cmd := Command(ctx, append([]string{"-c", "1", "./..."}, args...)...)
-		cmd.Dir = filepath.Dir(path)
+		cmd.Dir = module
 		commands = append(commands, cmd)
-		return cmd.Start()
-	}); err != nil {
-		return err
+		if err := cmd.Start(); err != nil {
+			return err
+		}
 	}
 	for _, cmd := range commands {
 		if err := cmd.Wait(); err != nil {
@@ -3,7 +3,6 @@ package sggovulncheck
 import (
 	"context"
 	"fmt"
-	"io/fs"
 	"os/exec"
 	"path/filepath"
 	"slices"
@@ -26,23 +25,22 @@ func Command(ctx context.Context, args ...string) *exec.Cmd {
 }
 
 // RunAll runs govulncheck on all the specified module paths, or all module paths from the current git root by default.
+// Module paths are module directories or paths of go.mod files, and the modules are listed with [sg.GoModules].
 func RunAll(ctx context.Context, modulePaths ...string) error {
-	var commands []*exec.Cmd
-	if err := filepath.WalkDir(sg.FromGitRoot(), func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() || d.Name() != "go.mod" {
-			return nil
-		}
-		if len(modulePaths) > 0 {
-			var shouldRunInModulePath bool
-			if slices.Contains(modulePaths, path) {
-				shouldRunInModulePath = true
-			}
-			if !shouldRunInModulePath {
-				return nil
-			}
+	return RunAllInModules(ctx, sg.GoModulesConfig{}, modulePaths...)
+}
+
+// RunAllInModules runs govulncheck like [RunAll], in the Go modules listed by [sg.GoModules] with config.
+func RunAllInModules(ctx context.Context, config sg.GoModulesConfig, modulePaths ...string) error {
+	modules, err := sg.GoModules(ctx, config)
+	if err != nil {
+		return err
+	}
+	commands := make([]*exec.Cmd, 0, len(modules))
+	for _, module := range modules {
+		path := filepath.Join(module, "go.mod")
+		if len(modulePaths) > 0 && !slices.Contains(modulePaths, module) && !slices.Contains(modulePaths, path) {
+			continue
 		}
 		relativePath, err := filepath.Rel(sg.FromGitRoot(), path)
 		if err != nil {
@@ -51,13 +49,13 @@ func RunAll(ctx context.Context, modulePaths ...string) error {
 		cmd := Command(
 			sg.AppendLoggerPrefix(ctx, fmt.Sprintf(" (%s): ", relativePath)),
 			"-C",
-			filepath.Dir(path),
+			module,
 			"./...",
 		)
 		commands = append(commands, cmd)
-		return cmd.Start()
-	}); err != nil {
-		return err
+		if err := cmd.Start(); err != nil {
+			return err
+		}
 	}
 	for _, cmd := range commands {
 		if err := cmd.Wait(); err != nil {